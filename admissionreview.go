@@ -0,0 +1,128 @@
+package mutatingwebhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/admission/v1"
+	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Decodes an AdmissionReview of any supported apiVersion into a v1 AdmissionReview.
+// The TypeMeta of the incoming review is preserved so that the response
+// can be encoded with the same apiVersion.
+func decodeAdmissionReview(body []byte) (v1.AdmissionReview, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(body, &typeMeta); err != nil {
+		return v1.AdmissionReview{}, err
+	}
+
+	switch typeMeta.APIVersion {
+	case "", v1.SchemeGroupVersion.String():
+		admissionReview := v1.AdmissionReview{}
+		if err := json.Unmarshal(body, &admissionReview); err != nil {
+			return v1.AdmissionReview{}, err
+		}
+		return admissionReview, nil
+	case v1beta1.SchemeGroupVersion.String():
+		admissionReview := v1beta1.AdmissionReview{}
+		if err := json.Unmarshal(body, &admissionReview); err != nil {
+			return v1.AdmissionReview{}, err
+		}
+		return v1.AdmissionReview{
+			TypeMeta: admissionReview.TypeMeta,
+			Request:  v1beta1RequestToV1(admissionReview.Request),
+		}, nil
+	default:
+		return v1.AdmissionReview{}, fmt.Errorf("unsupported AdmissionReview apiVersion %q", typeMeta.APIVersion)
+	}
+}
+
+// Encodes the AdmissionResponse as an AdmissionReview of the apiVersion in typeMeta.
+func encodeAdmissionReview(typeMeta metav1.TypeMeta, response v1.AdmissionResponse) ([]byte, error) {
+	switch typeMeta.APIVersion {
+	case v1beta1.SchemeGroupVersion.String():
+		return json.Marshal(v1beta1.AdmissionReview{
+			TypeMeta: typeMeta,
+			Response: v1ResponseToV1beta1(&response),
+		})
+	default:
+		return json.Marshal(v1.AdmissionReview{
+			TypeMeta: typeMeta,
+			Response: &response,
+		})
+	}
+}
+
+// Converts a v1beta1 AdmissionRequest into its v1 equivalent.
+func v1beta1RequestToV1(request *v1beta1.AdmissionRequest) *v1.AdmissionRequest {
+	if request == nil {
+		return nil
+	}
+
+	return &v1.AdmissionRequest{
+		UID:                request.UID,
+		Kind:               request.Kind,
+		Resource:           request.Resource,
+		SubResource:        request.SubResource,
+		RequestKind:        request.RequestKind,
+		RequestResource:    request.RequestResource,
+		RequestSubResource: request.RequestSubResource,
+		Name:               request.Name,
+		Namespace:          request.Namespace,
+		Operation:          v1.Operation(request.Operation),
+		UserInfo:           request.UserInfo,
+		Object:             request.Object,
+		OldObject:          request.OldObject,
+		DryRun:             request.DryRun,
+		Options:            request.Options,
+	}
+}
+
+// Converts a v1 AdmissionResponse into its v1beta1 equivalent.
+func v1ResponseToV1beta1(response *v1.AdmissionResponse) *v1beta1.AdmissionResponse {
+	if response == nil {
+		return nil
+	}
+
+	converted := &v1beta1.AdmissionResponse{
+		UID:              response.UID,
+		Allowed:          response.Allowed,
+		Result:           response.Result,
+		Patch:            response.Patch,
+		AuditAnnotations: response.AuditAnnotations,
+		Warnings:         response.Warnings,
+	}
+
+	if response.PatchType != nil {
+		patchType := v1beta1.PatchType(*response.PatchType)
+		converted.PatchType = &patchType
+	}
+
+	return converted
+}
+
+// Checks that the AdmissionReview carries a request that can be mutated.
+func validateAdmissionReview(admissionReview v1.AdmissionReview) error {
+	request := admissionReview.Request
+	if request == nil {
+		return fmt.Errorf("request is missing")
+	}
+
+	if request.UID == "" {
+		return fmt.Errorf("request.uid is missing")
+	}
+
+	switch request.Operation {
+	case v1.Create, v1.Update:
+		if len(request.Object.Raw) == 0 && request.Object.Object == nil {
+			return fmt.Errorf("request.object is required for operation %s", request.Operation)
+		}
+	case v1.Delete, v1.Connect:
+	default:
+		return fmt.Errorf("request.operation %q is not recognised", request.Operation)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package mutatingwebhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/admission/v1"
+	"k8s.io/api/admission/v1beta1"
+)
+
+func TestV1RoundTrip(t *testing.T) {
+	admission := getAdmission()
+	admission.APIVersion = "admission.k8s.io/v1"
+	admission.Kind = "AdmissionReview"
+	admission.Request.Operation = v1.Create
+	admission.Request.Object.Object = &payload
+
+	body, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	decoded, err := decodeAdmissionReview(body)
+	assert.NoError(t, err)
+	assert.Equal(t, admission.TypeMeta, decoded.TypeMeta)
+	assert.Equal(t, admission.Request.UID, decoded.Request.UID)
+	assert.Equal(t, v1.Create, decoded.Request.Operation)
+
+	patchType := v1.PatchTypeJSONPatch
+	body, err = encodeAdmissionReview(decoded.TypeMeta, v1.AdmissionResponse{
+		UID:       decoded.Request.UID,
+		Allowed:   true,
+		Patch:     []byte(`[]`),
+		PatchType: &patchType,
+	})
+	assert.NoError(t, err)
+
+	returned := v1.AdmissionReview{}
+	err = json.Unmarshal(body, &returned)
+	assert.NoError(t, err)
+	assert.Equal(t, admission.TypeMeta, returned.TypeMeta)
+	assert.Equal(t, admission.Request.UID, returned.Response.UID)
+	assert.Equal(t, v1.PatchTypeJSONPatch, *returned.Response.PatchType)
+}
+
+func TestV1beta1RoundTrip(t *testing.T) {
+	// The sample is a v1beta1 AdmissionReview
+	body, err := ioutil.ReadFile("samples/pod.json")
+	assert.NoError(t, err)
+
+	original := v1beta1.AdmissionReview{}
+	err = json.Unmarshal(body, &original)
+	assert.NoError(t, err)
+
+	decoded, err := decodeAdmissionReview(body)
+	assert.NoError(t, err)
+	assert.Equal(t, "admission.k8s.io/v1beta1", decoded.APIVersion)
+	assert.Equal(t, original.Request.UID, decoded.Request.UID)
+	assert.Equal(t, original.Request.Namespace, decoded.Request.Namespace)
+	assert.Equal(t, v1.Create, decoded.Request.Operation)
+	assert.Equal(t, original.Request.UserInfo, decoded.Request.UserInfo)
+	assert.JSONEq(t, string(original.Request.Object.Raw), string(decoded.Request.Object.Raw))
+
+	patchType := v1.PatchTypeJSONPatch
+	body, err = encodeAdmissionReview(decoded.TypeMeta, v1.AdmissionResponse{
+		UID:              decoded.Request.UID,
+		Allowed:          true,
+		Patch:            []byte(`[]`),
+		PatchType:        &patchType,
+		AuditAnnotations: map[string]string{"mutated": "true"},
+	})
+	assert.NoError(t, err)
+
+	returned := v1beta1.AdmissionReview{}
+	err = json.Unmarshal(body, &returned)
+	assert.NoError(t, err)
+	assert.Equal(t, "admission.k8s.io/v1beta1", returned.APIVersion)
+	assert.Equal(t, "AdmissionReview", returned.Kind)
+	assert.Equal(t, original.Request.UID, returned.Response.UID)
+	assert.True(t, returned.Response.Allowed)
+	assert.Equal(t, v1beta1.PatchTypeJSONPatch, *returned.Response.PatchType)
+	assert.Equal(t, "true", returned.Response.AuditAnnotations["mutated"])
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	_, err := decodeAdmissionReview([]byte(`{"apiVersion": "admission.k8s.io/v2", "kind": "AdmissionReview"}`))
+	assert.Error(t, err)
+}
+
+func TestValidateAdmissionReview(t *testing.T) {
+	admission := getAdmission()
+	admission.Request.Object.Object = &payload
+	assert.NoError(t, validateAdmissionReview(admission))
+
+	assert.EqualError(t, validateAdmissionReview(v1.AdmissionReview{}), "request is missing")
+
+	admission = getAdmission()
+	admission.Request.Object.Object = &payload
+	admission.Request.UID = ""
+	assert.EqualError(t, validateAdmissionReview(admission), "request.uid is missing")
+
+	admission = getAdmission()
+	admission.Request.Object.Object = &payload
+	admission.Request.Operation = "PATCH"
+	assert.EqualError(t, validateAdmissionReview(admission), `request.operation "PATCH" is not recognised`)
+
+	admission = getAdmission()
+	admission.Request.Operation = v1.Update
+	assert.EqualError(t, validateAdmissionReview(admission), "request.object is required for operation UPDATE")
+
+	admission = getAdmission()
+	admission.Request.Operation = v1.Delete
+	assert.NoError(t, validateAdmissionReview(admission))
+}
@@ -0,0 +1,195 @@
+package mutatingwebhook
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/statcan/mutating-webhook/jsonpatch"
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// Replaces the values of the patch operations applied to Secrets.
+const redacted = "REDACTED"
+
+// An AuditRecord describes the decision made on an admission request.
+// Conversions are not admission requests, and are not audited.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// The name of the mutator or validator which handled the request.
+	Webhook     string                       `json:"webhook"`
+	UID         types.UID                    `json:"uid,omitempty"`
+	UserInfo    *authenticationv1.UserInfo   `json:"userInfo,omitempty"`
+	Resource    *metav1.GroupVersionResource `json:"resource,omitempty"`
+	SubResource string                       `json:"subResource,omitempty"`
+	Namespace   string                       `json:"namespace,omitempty"`
+	Name        string                       `json:"name,omitempty"`
+	Operation   v1.Operation                 `json:"operation,omitempty"`
+	DryRun      bool                         `json:"dryRun"`
+	Allowed     bool                         `json:"allowed"`
+	// The status code and message of a denial or failure.
+	Code    int32  `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	// The operations of the JSON patch applied. Their values are redacted for Secrets.
+	Patch jsonpatch.JSONPatch `json:"patch,omitempty"`
+	// The time taken to handle the request.
+	LatencySeconds float64 `json:"latencySeconds"`
+}
+
+// An AuditSink receives the AuditRecord of every admission request.
+// A sink which also implements io.Closer is closed when the MutatingWebhook is shut down.
+type AuditSink interface {
+	Write(record AuditRecord) error
+}
+
+type klogAuditSink struct{}
+
+// Creates an AuditSink logging each record as JSON with klog.
+func NewKlogAuditSink() AuditSink {
+	return klogAuditSink{}
+}
+
+func (klogAuditSink) Write(record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	klog.Info(string(line))
+	return nil
+}
+
+type writerAuditSink struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// Creates an AuditSink writing each record to the writer as a line of JSON.
+func NewWriterAuditSink(writer io.Writer) AuditSink {
+	return &writerAuditSink{writer: writer}
+}
+
+func (sink *writerAuditSink) Write(record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	_, err = sink.writer.Write(append(line, '\n'))
+	return err
+}
+
+type fileAuditSink struct {
+	*writerAuditSink
+	file *os.File
+}
+
+// Creates an AuditSink appending each record to the file as a line of JSON.
+// The file is created if needed, readable only by its owner.
+func NewFileAuditSink(path string) (AuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileAuditSink{
+		writerAuditSink: &writerAuditSink{writer: file},
+		file:            file,
+	}, nil
+}
+
+// Closes the file.
+func (sink *fileAuditSink) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.file.Close()
+}
+
+// Creates the AuditRecord of a request. The request is nil when the review could not be decoded,
+// and the response when the request was rejected before reaching the mutator.
+func newAuditRecord(webhook string, request *v1.AdmissionRequest, response *v1.AdmissionResponse, err error, start time.Time) AuditRecord {
+	record := AuditRecord{
+		Time:           start.UTC(),
+		Webhook:        webhook,
+		LatencySeconds: time.Since(start).Seconds(),
+	}
+
+	if request != nil {
+		record.UID = request.UID
+		record.UserInfo = &request.UserInfo
+		record.Resource = &request.Resource
+		record.SubResource = request.SubResource
+		record.Namespace = request.Namespace
+		record.Name = request.Name
+		record.Operation = request.Operation
+		record.DryRun = request.DryRun != nil && *request.DryRun
+	}
+
+	if response == nil {
+		if err != nil {
+			record.Message = err.Error()
+		}
+		return record
+	}
+
+	record.Allowed = response.Allowed
+	if response.Result != nil {
+		record.Code = response.Result.Code
+		record.Message = response.Result.Message
+	}
+
+	if len(response.Patch) > 0 {
+		if patch, err := decodePatch(response.Patch); err != nil {
+			klog.Warningf("%s: could not decode the patch for the audit log: %v", webhook, err)
+		} else {
+			if request != nil && isSecret(request) {
+				for i := range patch {
+					if patch[i].Value != nil {
+						patch[i].Value = redacted
+					}
+				}
+			}
+			record.Patch = patch
+		}
+	}
+
+	return record
+}
+
+func decodePatch(raw []byte) (jsonpatch.JSONPatch, error) {
+	var patch jsonpatch.JSONPatch
+	if err := json.Unmarshal(raw, &patch); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// Whether the request is for a Secret, whose values must not be logged.
+func isSecret(request *v1.AdmissionRequest) bool {
+	return (request.Kind.Group == "" && request.Kind.Kind == "Secret") ||
+		(request.Resource.Group == "" && request.Resource.Resource == "secrets")
+}
+
+// Writes the record of the request to every AuditSink.
+func (mw *mutatingWebhook) audit(webhook string, request *v1.AdmissionRequest, response *v1.AdmissionResponse, err error, start time.Time) {
+	if len(mw.configs.AuditSinks) == 0 {
+		return
+	}
+
+	record := newAuditRecord(webhook, request, response, err, start)
+	for _, sink := range mw.configs.AuditSinks {
+		if err := sink.Write(record); err != nil {
+			klog.Errorf("%s: could not write the audit record of %q: %v", webhook, record.UID, err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package mutatingwebhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func auditRequest(kind, resource string) *v1.AdmissionRequest {
+	dryRun := true
+	return &v1.AdmissionRequest{
+		UID:       "This is unique!",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: kind},
+		Resource:  metav1.GroupVersionResource{Version: "v1", Resource: resource},
+		Namespace: "default",
+		Name:      "example",
+		Operation: v1.Create,
+		UserInfo:  authenticationv1.UserInfo{Username: "system:serviceaccount:default:deployer"},
+		DryRun:    &dryRun,
+	}
+}
+
+func TestNewAuditRecord(t *testing.T) {
+	response := &v1.AdmissionResponse{
+		Allowed: true,
+		Patch:   []byte(`[{"op":"add","path":"/data/password","value":"aHVudGVyMg=="},{"op":"remove","path":"/data/old"}]`),
+	}
+
+	record := newAuditRecord("default", auditRequest("ConfigMap", "configmaps"), response, nil, time.Now())
+	assert.Equal(t, "default", record.Webhook)
+	assert.Equal(t, "example", record.Name)
+	assert.Equal(t, "system:serviceaccount:default:deployer", record.UserInfo.Username)
+	assert.Equal(t, "configmaps", record.Resource.Resource)
+	assert.True(t, record.DryRun)
+	assert.True(t, record.Allowed)
+	assert.Equal(t, "aHVudGVyMg==", record.Patch[0].Value)
+
+	// The values are redacted for Secrets, but not the paths
+	record = newAuditRecord("default", auditRequest("Secret", "secrets"), response, nil, time.Now())
+	assert.Equal(t, redacted, record.Patch[0].Value)
+	assert.Equal(t, "/data/password", record.Patch[0].Path)
+	assert.Nil(t, record.Patch[1].Value)
+
+	line, err := json.Marshal(record)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(line), "aHVudGVyMg==")
+
+	// The request could not be decoded
+	record = newAuditRecord("default", nil, nil, fmt.Errorf("unexpected end of JSON input"), time.Now())
+	assert.False(t, record.Allowed)
+	assert.Nil(t, record.UserInfo)
+	assert.Equal(t, "unexpected end of JSON input", record.Message)
+}
+
+func TestFileAuditSink(t *testing.T) {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_audit_test_%d.log", time.Now().UnixNano()))
+	defer os.Remove(path)
+
+	sink, err := NewFileAuditSink(path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Write(AuditRecord{UID: "first"}))
+	assert.NoError(t, sink.Write(AuditRecord{UID: "second"}))
+	assert.NoError(t, sink.(*fileAuditSink).Close())
+
+	contents, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	assert.Len(t, lines, 2)
+
+	record := AuditRecord{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "second", string(record.UID))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestKlogAuditSink(t *testing.T) {
+	assert.NoError(t, NewKlogAuditSink().Write(AuditRecord{UID: "logged"}))
+}
+
+func TestAuditLog(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_audit_test_%d", time.Now().UnixNano()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	buffer := &bytes.Buffer{}
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath: &certFile,
+		KeyFilePath:  &keyFile,
+		AuditSinks:   []AuditSink{NewWriterAuditSink(buffer)},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mw.AddMutator("deny", AdaptMutator(&denyMute{})))
+	assert.NoError(t, mw.AddMutator("default", AdaptMutator(&mute{})))
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	admission := getAdmission()
+	admission.Request.Object.Object = &payload
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	client := getClient()
+	for _, path := range []string{"/mutate", "/mutate/deny", "/mutate/default"} {
+		resp, err := client.Post("https://localhost:8443"+path, "application/json", bytes.NewBuffer(requestBody))
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// Requests without JSON are audited too
+	resp, err := client.Post("https://localhost:8443/mutate/deny", "text/plain", bytes.NewBuffer(requestBody))
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// The records are written once the responses are
+	time.Sleep(50 * time.Millisecond)
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	assert.Len(t, lines, 4)
+
+	// Each route is told apart, whatever the name of its mutator
+	records := map[string]AuditRecord{}
+	for _, line := range lines {
+		record := AuditRecord{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		if record.UID == "" {
+			// The request rejected for its Content-Type
+			assert.Equal(t, "mutate/deny", record.Webhook)
+			assert.False(t, record.Allowed)
+			assert.Equal(t, `unsupported content type "text/plain"`, record.Message)
+			continue
+		}
+		records[record.Webhook] = record
+	}
+
+	assert.True(t, records["mutate"].Allowed)
+	assert.Equal(t, admission.Request.UID, records["mutate"].UID)
+	assert.Equal(t, v1.Create, records["mutate"].Operation)
+	assert.False(t, records["mutate/deny"].Allowed)
+	assert.NotZero(t, records["mutate/deny"].Code)
+	assert.True(t, records["mutate/default"].Allowed)
+}
@@ -0,0 +1,72 @@
+package mutatingwebhook
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+// A CertificateSource provides the certificate and key served by the webhook.
+// The certificate is validated before it is served, and the previous one keeps
+// being served if a new one is rejected.
+type CertificateSource interface {
+	// Returns the current certificate and key.
+	Certificate() (*tls.Certificate, error)
+	// Returns a channel which receives a value whenever the certificate may
+	// have changed, after which Certificate is called again.
+	// A nil channel means that the certificate never changes.
+	Changed() <-chan struct{}
+	// Stops watching for changes.
+	Close() error
+}
+
+// Signals a change without blocking, coalescing changes which have not been received yet.
+// The channel must be buffered.
+func notifyChanged(changed chan struct{}) {
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
+
+type pemCertificateSource struct {
+	cert *tls.Certificate
+}
+
+// Creates a CertificateSource which always provides the PEM encoded certificate and key.
+func NewPEMCertificateSource(certPEM, keyPEM []byte) (CertificateSource, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pemCertificateSource{cert: &cert}, nil
+}
+
+func (source *pemCertificateSource) Certificate() (*tls.Certificate, error) {
+	return source.cert, nil
+}
+
+func (source *pemCertificateSource) Changed() <-chan struct{} {
+	return nil
+}
+
+func (source *pemCertificateSource) Close() error {
+	return nil
+}
+
+// Creates a CertificateSource which provides a certificate for the DNS names,
+// signed by a CA generated along with it. Both are valid for the given validity.
+// The PEM encoded CA is returned for use as the caBundle of the webhook configuration.
+func NewSelfSignedCertificateSource(dnsNames []string, validity time.Duration) (CertificateSource, []byte, error) {
+	keypair, err := generateSelfSigned(dnsNames, validity, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	source, err := NewPEMCertificateSource(keypair.Cert, keypair.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return source, keypair.CA, nil
+}
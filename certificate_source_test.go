@@ -0,0 +1,70 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper returning a PEM encoded certificate and key for name.
+func pemKeypair(t *testing.T, name string) ([]byte, []byte) {
+	cert, _, key := newTestCertificate(t, serverTemplate(name), nil, nil)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return certPEM, keyPEM
+}
+
+func TestPEMCertificateSource(t *testing.T) {
+	certPEM, keyPEM := pemKeypair(t, "webhook1")
+
+	source, err := NewPEMCertificateSource(certPEM, keyPEM)
+	assert.NoError(t, err)
+	assert.Nil(t, source.Changed())
+
+	kpr, err := newKeypairReloader(source, setDefaults(MutatingWebhookConfigs{}))
+	assert.NoError(t, err)
+	defer kpr.Close()
+
+	assert.Equal(t, "webhook1", servedCommonName(t, kpr))
+
+	_, err = NewPEMCertificateSource(certPEM, []byte("not a key"))
+	assert.Error(t, err)
+}
+
+func TestSelfSignedCertificateSource(t *testing.T) {
+	source, caPEM, err := NewSelfSignedCertificateSource([]string{"mutating-webhook.default.svc"}, time.Hour)
+	assert.NoError(t, err)
+
+	cert, err := source.Certificate()
+	assert.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	assert.True(t, pool.AppendCertsFromPEM(caPEM))
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "mutating-webhook.default.svc", Roots: pool})
+	assert.NoError(t, err)
+}
+
+// The configured source is used instead of the files.
+func TestConfiguredCertificateSource(t *testing.T) {
+	certPEM, keyPEM := pemKeypair(t, "webhook1")
+	source, err := NewPEMCertificateSource(certPEM, keyPEM)
+	assert.NoError(t, err)
+
+	missing := "/nonexistent/tls.crt"
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath:      &missing,
+		CertificateSource: source,
+	})
+	assert.NoError(t, err)
+	defer mw.Shutdown(context.TODO())
+
+	assert.Equal(t, "webhook1", servedCommonName(t, mw.(*mutatingWebhook).kpr))
+}
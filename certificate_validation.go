@@ -0,0 +1,108 @@
+package mutatingwebhook
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// Describes what a certificate must satisfy before it is served.
+type certificateRequirements struct {
+	// The DNS names the certificate must be valid for.
+	dnsNames []string
+	// The file path to the PEM bundle of CAs the certificate must chain to.
+	// When empty, the chain is not verified.
+	caFilePath string
+}
+
+// Checks that the certificate is currently valid, usable by a server,
+// valid for the required DNS names and, if configured, issued by a trusted CA.
+func (cr certificateRequirements) validate(cert *tls.Certificate, now time.Time) error {
+	if len(cert.Certificate) == 0 {
+		return fmt.Errorf("no certificate found")
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return err
+		}
+	}
+
+	if now.Before(leaf.NotBefore) {
+		return fmt.Errorf("certificate is not valid before %s", leaf.NotBefore.Format(time.RFC3339))
+	}
+
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("certificate expired on %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	if !allowsServerAuth(leaf) {
+		return fmt.Errorf("certificate cannot be used for server authentication")
+	}
+
+	for _, name := range cr.dnsNames {
+		if err := leaf.VerifyHostname(name); err != nil {
+			return err
+		}
+	}
+
+	if cr.caFilePath == "" {
+		return nil
+	}
+
+	roots, err := loadCertPool(cr.caFilePath)
+	if err != nil {
+		return err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, der := range cert.Certificate[1:] {
+		intermediate, err := x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		intermediates.AddCert(intermediate)
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return err
+}
+
+// A certificate without extended key usages may be used for any purpose.
+func allowsServerAuth(leaf *x509.Certificate) bool {
+	if len(leaf.ExtKeyUsage) == 0 {
+		return true
+	}
+
+	for _, usage := range leaf.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageServerAuth || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reads a PEM bundle of certificates into a pool.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(contents) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return pool, nil
+}
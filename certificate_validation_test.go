@@ -0,0 +1,138 @@
+package mutatingwebhook
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper creating a certificate signed by parent, or self-signed when parent is nil.
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*tls.Certificate, *x509.Certificate, *rsa.PrivateKey) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	if parent == nil {
+		parent, parentKey = template, privateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &privateKey.PublicKey, parentKey)
+	assert.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: privateKey}, leaf, privateKey
+}
+
+func serverTemplate(name string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(2019),
+		Subject: pkix.Name{
+			Organization: []string{"Statistics Canada"},
+			CommonName:   name,
+		},
+		DNSNames:    []string{name},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().AddDate(0, 0, 1),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	}
+}
+
+func TestValidateCertificate(t *testing.T) {
+	valid, _, _ := newTestCertificate(t, serverTemplate("mutating-webhook.default.svc"), nil, nil)
+
+	expiredTemplate := serverTemplate("mutating-webhook.default.svc")
+	expiredTemplate.NotAfter = time.Now().Add(-time.Minute)
+	expired, _, _ := newTestCertificate(t, expiredTemplate, nil, nil)
+
+	futureTemplate := serverTemplate("mutating-webhook.default.svc")
+	futureTemplate.NotBefore = time.Now().Add(time.Hour)
+	future, _, _ := newTestCertificate(t, futureTemplate, nil, nil)
+
+	clientTemplate := serverTemplate("mutating-webhook.default.svc")
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	client, _, _ := newTestCertificate(t, clientTemplate, nil, nil)
+
+	requirements := certificateRequirements{dnsNames: []string{"mutating-webhook.default.svc"}}
+
+	assert.NoError(t, requirements.validate(valid, time.Now()))
+	assert.Error(t, requirements.validate(expired, time.Now()))
+	assert.Error(t, requirements.validate(future, time.Now()))
+	assert.EqualError(t, requirements.validate(client, time.Now()), "certificate cannot be used for server authentication")
+
+	requirements.dnsNames = []string{"mutating-webhook.other.svc"}
+	assert.Error(t, requirements.validate(valid, time.Now()))
+}
+
+func TestValidateCertificateChain(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_ca_test_%d", time.Now().UnixNano()))
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	caTemplate := serverTemplate("mutating-webhook-ca")
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	_, ca, caKey := newTestCertificate(t, caTemplate, nil, nil)
+
+	caFile := filepath.Join(certDir, "ca.crt")
+	caPEM := new(bytes.Buffer)
+	pem.Encode(caPEM, &pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	assert.NoError(t, ioutil.WriteFile(caFile, caPEM.Bytes(), 0662))
+
+	issued, _, _ := newTestCertificate(t, serverTemplate("mutating-webhook.default.svc"), ca, caKey)
+	selfSigned, _, _ := newTestCertificate(t, serverTemplate("mutating-webhook.default.svc"), nil, nil)
+
+	requirements := certificateRequirements{caFilePath: caFile}
+
+	assert.NoError(t, requirements.validate(issued, time.Now()))
+	assert.Error(t, requirements.validate(selfSigned, time.Now()))
+
+	requirements.caFilePath = filepath.Join(certDir, "missing.crt")
+	assert.Error(t, requirements.validate(issued, time.Now()))
+}
+
+// A certificate which does not meet the requirements is not swapped in.
+func TestReloadRejectsInvalidCertificate(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_reject_test_%d", time.Now().UnixNano()))
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	assert.NoError(t, writeCerts(certDir, "webhook1"))
+
+	kpr, err := newFileKeypairReloader(certDir, setDefaults(MutatingWebhookConfigs{}))
+	assert.NoError(t, err)
+	defer kpr.Close()
+
+	// The new certificate has expired
+	expiredTemplate := serverTemplate("webhook2")
+	expiredTemplate.NotAfter = time.Now().Add(-time.Minute)
+	expired, _, key := newTestCertificate(t, expiredTemplate, nil, nil)
+
+	certPEM := new(bytes.Buffer)
+	pem.Encode(certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: expired.Certificate[0]})
+	keyPEM := new(bytes.Buffer)
+	pem.Encode(keyPEM, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(certDir, "tls.cert"), certPEM.Bytes(), 0662))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(certDir, "tls.key"), keyPEM.Bytes(), 0662))
+
+	assert.Error(t, kpr.maybeReload())
+	assert.Equal(t, "webhook1", servedCommonName(t, kpr))
+}
@@ -0,0 +1,119 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/statcan/mutating-webhook/jsonpatch"
+	v1 "k8s.io/api/admission/v1"
+)
+
+type chain struct {
+	mutators []ContextMutator
+}
+
+// Composes the mutators into a single ContextMutator.
+// Each mutator sees the object as patched by the mutators before it,
+// and the response carries a single JSON patch against the original object.
+// AuditAnnotations and Warnings of all mutators are merged, with later
+// mutators overriding the annotations of earlier ones.
+// The chain stops at the first mutator which returns an error or denies the request.
+// It takes ContextMutators so that the request's context reaches each of them,
+// see ChainMutators to compose plain Mutators.
+func Chain(mutators ...ContextMutator) ContextMutator {
+	return &chain{mutators: mutators}
+}
+
+type mutatorChain struct {
+	chain ContextMutator
+}
+
+// Composes plain Mutators into a single Mutator, as Chain does.
+// It can be passed to NewMutatingWebhook as is.
+func ChainMutators(mutators ...Mutator) Mutator {
+	adapted := make([]ContextMutator, 0, len(mutators))
+	for _, mutator := range mutators {
+		adapted = append(adapted, AdaptMutator(mutator))
+	}
+
+	return &mutatorChain{chain: Chain(adapted...)}
+}
+
+// The mutators ignore the context, so none is passed on.
+func (c *mutatorChain) Mutate(request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	return c.chain.Mutate(context.Background(), request)
+}
+
+func (c *chain) Mutate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	response := v1.AdmissionResponse{
+		UID:     request.UID,
+		Allowed: true,
+	}
+
+	original := request.Object.Raw
+	current := original
+
+	for i, mutator := range c.mutators {
+		request.Object.Raw = current
+		request.Object.Object = nil
+
+		mutatorResponse, err := mutator.Mutate(ctx, request)
+		if err != nil {
+			return response, err
+		}
+
+		for key, value := range mutatorResponse.AuditAnnotations {
+			if response.AuditAnnotations == nil {
+				response.AuditAnnotations = map[string]string{}
+			}
+			response.AuditAnnotations[key] = value
+		}
+		response.Warnings = append(response.Warnings, mutatorResponse.Warnings...)
+
+		// The denial carries what the mutators before it reported
+		if !mutatorResponse.Allowed {
+			mutatorResponse.UID = request.UID
+			mutatorResponse.AuditAnnotations = response.AuditAnnotations
+			mutatorResponse.Warnings = response.Warnings
+			return mutatorResponse, nil
+		}
+
+		if len(mutatorResponse.Patch) == 0 {
+			continue
+		}
+
+		if mutatorResponse.PatchType != nil && *mutatorResponse.PatchType != v1.PatchTypeJSONPatch {
+			return response, fmt.Errorf("mutator %d returned unsupported patch type %q", i, *mutatorResponse.PatchType)
+		}
+
+		patch := jsonpatch.JSONPatch{}
+		if err := json.Unmarshal(mutatorResponse.Patch, &patch); err != nil {
+			return response, fmt.Errorf("mutator %d returned an invalid patch: %w", i, err)
+		}
+
+		if current, err = patch.Apply(current); err != nil {
+			return response, fmt.Errorf("mutator %d returned a patch which does not apply: %w", i, err)
+		}
+	}
+
+	// Without an object there is nothing to patch
+	if len(original) == 0 {
+		return response, nil
+	}
+
+	patch, err := jsonpatch.CreatePatch(original, current)
+	if err != nil {
+		return response, err
+	}
+
+	if len(patch) > 0 {
+		if response.Patch, err = json.Marshal(patch); err != nil {
+			return response, err
+		}
+		patchType := v1.PatchTypeJSONPatch
+		response.PatchType = &patchType
+	}
+
+	return response, nil
+}
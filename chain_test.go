@@ -0,0 +1,238 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/statcan/mutating-webhook/jsonpatch"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Responds with the given patch, annotations and warnings.
+type patchMute struct {
+	patch       jsonpatch.JSONPatch
+	annotations map[string]string
+	warnings    []string
+	seen        *corev1.Pod
+}
+
+func (m *patchMute) Mutate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	m.seen = &corev1.Pod{}
+	if err := json.Unmarshal(request.Object.Raw, m.seen); err != nil {
+		return v1.AdmissionResponse{}, err
+	}
+
+	patch, err := json.Marshal(m.patch)
+	if err != nil {
+		return v1.AdmissionResponse{}, err
+	}
+
+	patchType := v1.PatchTypeJSONPatch
+	return v1.AdmissionResponse{
+		UID:              request.UID,
+		Allowed:          true,
+		Patch:            patch,
+		PatchType:        &patchType,
+		AuditAnnotations: m.annotations,
+		Warnings:         m.warnings,
+	}, nil
+}
+
+func TestChain(t *testing.T) {
+	labels := &patchMute{
+		patch: jsonpatch.JSONPatch{
+			{Op: "add", Path: "/metadata/labels", Value: map[string]string{"team": "a"}},
+		},
+		annotations: map[string]string{"labels": "added", "shared": "labels"},
+		warnings:    []string{"labels were added"},
+	}
+	images := &patchMute{
+		patch: jsonpatch.JSONPatch{
+			{Op: "replace", Path: "/spec/containers/0/image", Value: "otherimage"},
+			{Op: "add", Path: "/metadata/labels/image", Value: "otherimage"},
+		},
+		annotations: map[string]string{"shared": "images"},
+		warnings:    []string{"image was replaced"},
+	}
+
+	raw, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	admission := getAdmission()
+	admission.Request.Object.Raw = raw
+
+	response, err := Chain(labels, images).Mutate(context.TODO(), *admission.Request)
+	assert.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Equal(t, admission.Request.UID, response.UID)
+
+	// The second mutator sees the changes of the first
+	assert.Equal(t, "a", images.seen.Labels["team"])
+
+	assert.Equal(t, map[string]string{"labels": "added", "shared": "images"}, response.AuditAnnotations)
+	assert.Equal(t, []string{"labels were added", "image was replaced"}, response.Warnings)
+
+	// A single patch applies to the original object
+	patch := jsonpatch.JSONPatch{}
+	err = json.Unmarshal(response.Patch, &patch)
+	assert.NoError(t, err)
+
+	patched, err := patch.Apply(raw)
+	assert.NoError(t, err)
+
+	pod := corev1.Pod{}
+	err = json.Unmarshal(patched, &pod)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "a", "image": "otherimage"}, pod.Labels)
+	assert.Equal(t, "otherimage", pod.Spec.Containers[0].Image)
+}
+
+func TestChainDenied(t *testing.T) {
+	after := &patchMute{}
+
+	raw, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	admission := getAdmission()
+	admission.Request.Object.Raw = raw
+
+	_, err = Chain(&patchMute{}, AdaptMutator(&denyMute{}), after).Mutate(context.TODO(), *admission.Request)
+
+	var denial *DenyError
+	assert.True(t, errors.As(err, &denial))
+	assert.Equal(t, int32(http.StatusForbidden), denial.Code)
+	assert.Nil(t, after.seen)
+
+	disallow := AdaptMutator(&disallowMute{})
+	response, err := Chain(&patchMute{}, disallow, after).Mutate(context.TODO(), *admission.Request)
+	assert.NoError(t, err)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, admission.Request.UID, response.UID)
+	assert.Nil(t, after.seen)
+}
+
+func TestChainDeniedKeepsWarnings(t *testing.T) {
+	raw, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	admission := getAdmission()
+	admission.Request.Object.Raw = raw
+
+	warn := &patchMute{
+		annotations: map[string]string{"warned": "true"},
+		warnings:    []string{"the image is deprecated"},
+	}
+
+	response, err := Chain(warn, AdaptMutator(&disallowMute{})).Mutate(context.TODO(), *admission.Request)
+	assert.NoError(t, err)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, []string{"the image is deprecated", "not allowed"}, response.Warnings)
+	assert.Equal(t, map[string]string{"warned": "true"}, response.AuditAnnotations)
+
+	response, err = Chain(warn, AdaptMutator(&denyMute{})).Mutate(context.TODO(), *admission.Request)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"the image is deprecated"}, response.Warnings)
+	assert.Equal(t, map[string]string{"warned": "true"}, response.AuditAnnotations)
+}
+
+func TestChainLargeIntegers(t *testing.T) {
+	raw, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	admission := getAdmission()
+	admission.Request.Object.Raw = raw
+
+	// Larger than the integers a float64 represents exactly
+	priority := &patchMute{
+		patch: jsonpatch.JSONPatch{
+			{Op: "add", Path: "/spec/activeDeadlineSeconds", Value: int64(9007199254740993)},
+		},
+	}
+	image := &patchMute{
+		patch: jsonpatch.JSONPatch{
+			{Op: "replace", Path: "/spec/containers/0/image", Value: "otherimage"},
+		},
+	}
+
+	response, err := Chain(priority, image).Mutate(context.TODO(), *admission.Request)
+	assert.NoError(t, err)
+	assert.Contains(t, string(response.Patch), "9007199254740993")
+
+	patch := jsonpatch.JSONPatch{}
+	assert.NoError(t, json.Unmarshal(response.Patch, &patch))
+
+	patched, err := patch.Apply(raw)
+	assert.NoError(t, err)
+
+	pod := corev1.Pod{}
+	assert.NoError(t, json.Unmarshal(patched, &pod))
+	assert.Equal(t, int64(9007199254740993), *pod.Spec.ActiveDeadlineSeconds)
+	assert.Equal(t, "otherimage", pod.Spec.Containers[0].Image)
+}
+
+// A plain Mutator adding a label.
+type labelMute struct{}
+
+func (m *labelMute) Mutate(request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	patch, err := json.Marshal(jsonpatch.JSONPatch{
+		{Op: "add", Path: "/metadata/labels", Value: map[string]string{"team": "a"}},
+	})
+	if err != nil {
+		return v1.AdmissionResponse{}, err
+	}
+
+	return v1.AdmissionResponse{Allowed: true, Patch: patch}, nil
+}
+
+func TestChainMutators(t *testing.T) {
+	raw, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	admission := getAdmission()
+	admission.Request.Object.Raw = raw
+
+	response, err := ChainMutators(&labelMute{}, &disallowMute{}).Mutate(*admission.Request)
+	assert.NoError(t, err)
+	assert.False(t, response.Allowed)
+
+	var mutator Mutator = ChainMutators(&labelMute{})
+	response, err = mutator.Mutate(*admission.Request)
+	assert.NoError(t, err)
+	assert.True(t, response.Allowed)
+
+	patch := jsonpatch.JSONPatch{}
+	assert.NoError(t, json.Unmarshal(response.Patch, &patch))
+
+	patched, err := patch.Apply(raw)
+	assert.NoError(t, err)
+
+	pod := corev1.Pod{}
+	assert.NoError(t, json.Unmarshal(patched, &pod))
+	assert.Equal(t, map[string]string{"team": "a"}, pod.Labels)
+}
+
+func TestChainInvalidPatch(t *testing.T) {
+	raw, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	admission := getAdmission()
+	admission.Request.Object.Raw = raw
+
+	broken := &patchMute{
+		patch: jsonpatch.JSONPatch{{Op: "remove", Path: "/metadata/missing"}},
+	}
+
+	_, err = Chain(broken).Mutate(context.TODO(), *admission.Request)
+	assert.Error(t, err)
+}
+
+type disallowMute struct{}
+
+func (m *disallowMute) Mutate(request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	return v1.AdmissionResponse{Allowed: false, Warnings: []string{"not allowed"}}, nil
+}
@@ -0,0 +1,186 @@
+package mutatingwebhook
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Holds the pool of client CAs, reloading it when the file changes.
+type clientCAReloader struct {
+	path     string
+	poolMu   sync.RWMutex
+	pool     *x509.CertPool
+	notifier *fileNotifier
+}
+
+// Creates the struct that allows for the management of the client CA reloading.
+func newClientCAReloader(path string, watch bool, pollPeriod time.Duration) (*clientCAReloader, error) {
+	pool, err := loadCertPool(path)
+	if err != nil {
+		return nil, err
+	}
+
+	notifier, err := newFileNotifier([]string{path}, watch, pollPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &clientCAReloader{
+		path:     path,
+		pool:     pool,
+		notifier: notifier,
+	}
+
+	go result.watch()
+
+	return result, nil
+}
+
+// Reloads the pool whenever the file changes, until the notifier is closed.
+func (ccr *clientCAReloader) watch() {
+	for {
+		select {
+		case <-ccr.notifier.stop:
+			return
+		case <-ccr.notifier.Changed():
+			klog.Infof("Client CA updated - reloading")
+			if err := ccr.maybeReload(); err != nil {
+				klog.Errorf("Could not reload: %v", err)
+			} else {
+				klog.Infof("Reload complete")
+			}
+		}
+	}
+}
+
+// Attempts to reload the pool.
+// The current pool is kept if the file cannot be loaded.
+func (ccr *clientCAReloader) maybeReload() error {
+	pool, err := loadCertPool(ccr.path)
+	if err != nil {
+		return err
+	}
+
+	ccr.poolMu.Lock()
+	ccr.pool = pool
+	ccr.poolMu.Unlock()
+	return nil
+}
+
+// Returns the current pool of client CAs.
+func (ccr *clientCAReloader) certPool() *x509.CertPool {
+	ccr.poolMu.RLock()
+	defer ccr.poolMu.RUnlock()
+	return ccr.pool
+}
+
+// Stops watching for changes.
+func (ccr *clientCAReloader) Close() error {
+	return ccr.notifier.Close()
+}
+
+// Whether the mode requires client certificates to be verified against the client CAs.
+func verifiesClientCert(mode tls.ClientAuthType) bool {
+	return mode == tls.VerifyClientCertIfGiven || mode == tls.RequireAndVerifyClientCert
+}
+
+// Checks that the client authentication configs are consistent.
+func validateClientAuth(configs MutatingWebhookConfigs) error {
+	mode := *configs.ClientAuth
+
+	switch mode {
+	case tls.NoClientCert, tls.RequestClientCert, tls.RequireAnyClientCert,
+		tls.VerifyClientCertIfGiven, tls.RequireAndVerifyClientCert:
+	default:
+		return fmt.Errorf("unknown ClientAuth %d", mode)
+	}
+
+	if verifiesClientCert(mode) && *configs.ClientCAFilePath == "" {
+		return fmt.Errorf("ClientAuth %s requires a ClientCAFilePath", mode)
+	}
+
+	if len(configs.AllowedClientNames) > 0 && !verifiesClientCert(mode) {
+		return fmt.Errorf("AllowedClientNames requires ClientAuth to verify client certificates, got %s", mode)
+	}
+
+	return nil
+}
+
+// Checks the client certificate of the connection according to the configs.
+// Returns the HTTP status to reply with when the client is not accepted.
+func (mw *mutatingWebhook) authenticateClient(state *tls.ConnectionState) (int, error) {
+	mode := *mw.configs.ClientAuth
+	if mode == tls.NoClientCert {
+		return 0, nil
+	}
+
+	var peerCertificates []*x509.Certificate
+	if state != nil {
+		peerCertificates = state.PeerCertificates
+	}
+
+	if len(peerCertificates) == 0 {
+		if mode == tls.RequireAnyClientCert || mode == tls.RequireAndVerifyClientCert || len(mw.configs.AllowedClientNames) > 0 {
+			return http.StatusUnauthorized, fmt.Errorf("a client certificate is required")
+		}
+		return 0, nil
+	}
+
+	if !verifiesClientCert(mode) {
+		return 0, nil
+	}
+
+	leaf := peerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range peerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         mw.clientCAs.certPool(),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return http.StatusUnauthorized, fmt.Errorf("invalid client certificate: %w", err)
+	}
+
+	if len(mw.configs.AllowedClientNames) > 0 && !clientNameAllowed(leaf, mw.configs.AllowedClientNames) {
+		return http.StatusForbidden, fmt.Errorf("client %q is not allowed", leaf.Subject.CommonName)
+	}
+
+	return 0, nil
+}
+
+// Whether the common name or one of the DNS names of the certificate is allowed.
+func clientNameAllowed(cert *x509.Certificate, allowed []string) bool {
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+
+	for _, name := range names {
+		for _, allowedName := range allowed {
+			if name == allowedName {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Only lets the clients accepted by authenticateClient through to the handler.
+func (mw *mutatingWebhook) requireClient(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if status, err := mw.authenticateClient(r.TLS); err != nil {
+			klog.Warningf("Rejected client %s on %s: %v", r.RemoteAddr, r.URL.Path, err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		handler(w, r)
+	}
+}
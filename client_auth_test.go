@@ -0,0 +1,161 @@
+package mutatingwebhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func caTemplate(name string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(0, 0, 1),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+}
+
+func clientTemplate(name string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(0, 0, 1),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
+
+// Helper returning a client presenting a certificate for name issued by the CA.
+func getClientWithCert(t *testing.T, name string, ca *x509.Certificate, caKey *rsa.PrivateKey) *http.Client {
+	cert, _, _ := newTestCertificate(t, clientTemplate(name), ca, caKey)
+
+	client := getClient()
+	client.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{*cert}
+	return client
+}
+
+func writeCA(t *testing.T, path string, ca *x509.Certificate) {
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	assert.NoError(t, ioutil.WriteFile(path, caPEM, 0662))
+}
+
+func TestValidateClientAuth(t *testing.T) {
+	caFile := "./ca.crt"
+	tests := []struct {
+		mode    tls.ClientAuthType
+		caFile  *string
+		names   []string
+		isValid bool
+	}{
+		{tls.NoClientCert, nil, nil, true},
+		{tls.RequireAnyClientCert, nil, nil, true},
+		{tls.RequireAndVerifyClientCert, nil, nil, false},
+		{tls.RequireAndVerifyClientCert, &caFile, nil, true},
+		{tls.VerifyClientCertIfGiven, &caFile, []string{"kube-apiserver"}, true},
+		{tls.RequireAnyClientCert, &caFile, []string{"kube-apiserver"}, false},
+		{tls.ClientAuthType(42), nil, nil, false},
+	}
+
+	for _, test := range tests {
+		mode := test.mode
+		err := validateClientAuth(setDefaults(MutatingWebhookConfigs{
+			ClientAuth:         &mode,
+			ClientCAFilePath:   test.caFile,
+			AllowedClientNames: test.names,
+		}))
+		assert.Equal(t, test.isValid, err == nil, fmt.Sprintf("%s %v: %v", test.mode, test.names, err))
+	}
+}
+
+func TestClientNameAllowed(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "kube-apiserver"},
+		DNSNames: []string{"apiserver.example.com"},
+	}
+
+	assert.True(t, clientNameAllowed(cert, []string{"kube-apiserver"}))
+	assert.True(t, clientNameAllowed(cert, []string{"other", "apiserver.example.com"}))
+	assert.False(t, clientNameAllowed(cert, []string{"other"}))
+}
+
+func TestMutualTLS(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_mtls_test_%d", time.Now().UnixNano()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+	caFile := filepath.Join(certDir, "ca.crt")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	_, ca, caKey := newTestCertificate(t, caTemplate("client-ca"), nil, nil)
+	writeCA(t, caFile, ca)
+
+	mode := tls.RequireAndVerifyClientCert
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath:       &certFile,
+		KeyFilePath:        &keyFile,
+		ClientCAFilePath:   &caFile,
+		ClientAuth:         &mode,
+		AllowedClientNames: []string{"kube-apiserver"},
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	admission := getAdmission()
+	admission.Request.Object.Object = &payload
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	postStatus := func(client *http.Client) int {
+		resp, err := client.Post("https://localhost:8443/mutate", "application/json", bytes.NewBuffer(requestBody))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// The probes are reachable without a client certificate
+	resp, err := getClient().Get("https://localhost:8443/_healthz")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.Equal(t, http.StatusUnauthorized, postStatus(getClient()))
+	assert.Equal(t, http.StatusOK, postStatus(getClientWithCert(t, "kube-apiserver", ca, caKey)))
+	assert.Equal(t, http.StatusForbidden, postStatus(getClientWithCert(t, "intruder", ca, caKey)))
+
+	// Issued by another CA
+	_, otherCA, otherCAKey := newTestCertificate(t, caTemplate("other-ca"), nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, postStatus(getClientWithCert(t, "kube-apiserver", otherCA, otherCAKey)))
+
+	// Which is accepted once it replaces the CA
+	writeCA(t, caFile, otherCA)
+	time.Sleep(4 * reloadDebounce)
+
+	assert.Equal(t, http.StatusOK, postStatus(getClientWithCert(t, "kube-apiserver", otherCA, otherCAKey)))
+	assert.Equal(t, http.StatusUnauthorized, postStatus(getClientWithCert(t, "kube-apiserver", ca, caKey)))
+}
@@ -1,7 +1,23 @@
 package mutatingwebhook
 
 import (
+	"crypto/tls"
 	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FailurePolicy defines how errors returned by a Mutator, other than a DenyError,
+// are reported to the API server.
+type FailurePolicy string
+
+const (
+	// Errors deny the request.
+	FailurePolicyFail FailurePolicy = "Fail"
+	// Errors allow the request through unmodified.
+	FailurePolicyIgnore FailurePolicy = "Ignore"
 )
 
 // Default values used to fill the MutatingWebhookConfigs
@@ -12,6 +28,22 @@ var (
 	maxHeaderBytes = 0
 	certFilePath   = "./certs/tls.crt"
 	keyFilePath    = "./certs/tls.key"
+	mutateTimeout  = 10 * time.Second
+	failurePolicy  = FailurePolicyFail
+	watchCerts     = true
+	certPollPeriod = time.Duration(0)
+	certCAFilePath = ""
+	// Warn a week and a day before the certificate expires
+	certExpiryWarnings = []time.Duration{7 * 24 * time.Hour, 24 * time.Hour}
+	certExpiryCritical = time.Duration(0)
+	selfSignedValidity = 365 * 24 * time.Hour
+	writeSelfSigned    = false
+	selfSignedCAPath   = "./certs/ca.crt"
+	clientCAFilePath   = ""
+	clientAuth         = tls.NoClientCert
+	tlsProfile         = TLSProfileIntermediate
+	enableMetrics      = false
+	metricsAddr        = ""
 )
 
 // Any values left nil will use default values.
@@ -28,6 +60,86 @@ type MutatingWebhookConfigs struct {
 	CertFilePath *string
 	// The file path to the key file from which the certificate is derived.
 	KeyFilePath *string
+	// The deadline applied to the context passed to a ContextMutator,
+	// on top of the request's own context. When 0, no deadline is added.
+	MutateTimeout *time.Duration
+	// Whether errors from the Mutator deny (Fail) or allow (Ignore) the request.
+	FailurePolicy *FailurePolicy
+	// Whether to reload the certificate when file system events
+	// are received for the directories of the cert and key files.
+	WatchCerts *bool
+	// How often to check the cert and key files for changes, for file systems
+	// which do not emit events. When 0, the files are not polled.
+	CertPollPeriod *time.Duration
+	// The DNS names the certificate must be valid for. A certificate
+	// missing any of them is rejected. When nil, no names are required.
+	CertDNSNames []string
+	// The file path to a PEM bundle of CAs which must have issued the certificate.
+	// When empty, the certificate chain is not verified.
+	CertCAFilePath *string
+	// A warning is logged when the remaining validity of the certificate
+	// drops below each of these durations.
+	CertExpiryWarnings []time.Duration
+	// The readiness probe fails once the remaining validity of the certificate
+	// drops below this duration. When 0, it only fails once the certificate has expired.
+	CertExpiryCritical *time.Duration
+	// The DNS names to generate a self-signed certificate for when the cert
+	// or key file is absent. When nil, the files are required.
+	SelfSignedDNSNames []string
+	// How long the generated certificate and its CA are valid for.
+	SelfSignedValidity *time.Duration
+	// Whether to write the generated certificate and key to the cert and key
+	// file paths, and the CA to SelfSignedCAFilePath. When false, they are only kept in memory.
+	WriteSelfSigned *bool
+	// The file path to write the PEM encoded CA of the generated certificate to,
+	// for use as the caBundle of the webhook configuration.
+	SelfSignedCAFilePath *string
+	// Where the served certificate comes from. When nil, it is read from
+	// the cert and key files, or generated as configured above.
+	// Otherwise, the file and self-signed configs are ignored.
+	// The source is closed when the MutatingWebhook is shut down.
+	CertificateSource CertificateSource
+	// Additional certificates, served to the clients requesting their server name
+	// through SNI. Each must be valid for its server name, and is reloaded independently.
+	// Clients requesting any other server name are served the default certificate.
+	// The sources are closed when the MutatingWebhook is shut down.
+	SNICertificateSources map[string]CertificateSource
+	// The file path to a PEM bundle of CAs used to verify client certificates.
+	// It is reloaded like the certificate. Required when ClientAuth verifies certificates.
+	ClientCAFilePath *string
+	// Which client certificates are required on the mutation, validation and conversion
+	// routes, with the same meaning as tls.Config.ClientAuth. The other endpoints,
+	// such as the probes, do not require a client certificate.
+	ClientAuth *tls.ClientAuthType
+	// The common names or DNS names, one of which a client certificate must have.
+	// When nil, any verified client certificate is accepted.
+	AllowedClientNames []string
+	// The TLS versions, cipher suites and curves accepted by the server.
+	TLSProfile *TLSProfile
+	// Overrides the minimum TLS version of the TLSProfile, such as tls.VersionTLS13.
+	TLSMinVersion *uint16
+	// Overrides the TLS 1.2 cipher suites of the TLSProfile.
+	// The cipher suites of TLS 1.3 cannot be configured.
+	TLSCipherSuites []uint16
+	// Overrides the elliptic curves of the TLSProfile, in order of preference.
+	TLSCurves []tls.CurveID
+	// Whether to serve Prometheus metrics on /metrics.
+	EnableMetrics *bool
+	// The TCP address of a separate plain HTTP listener serving the metrics.
+	// When empty, they are served by the webhook's own server.
+	MetricsAddr *string
+	// Creates the OpenTelemetry spans of admission requests.
+	// When nil, a TracerProvider exporting to the TraceExporter is used.
+	TracerProvider trace.TracerProvider
+	// Where spans are exported to when no TracerProvider is set.
+	// When both are nil, spans are not recorded.
+	TraceExporter sdktrace.SpanExporter
+	// Extracts the trace context propagated by the caller from the request headers.
+	// When nil, the W3C Trace Context and Baggage headers are used.
+	TracePropagator propagation.TextMapPropagator
+	// Where the AuditRecord of every admission request is written.
+	// When nil, no audit log is kept.
+	AuditSinks []AuditSink
 }
 
 // Sets default values.
@@ -57,5 +169,65 @@ func setDefaults(configs MutatingWebhookConfigs) MutatingWebhookConfigs {
 		configs.KeyFilePath = &keyFilePath
 	}
 
+	if configs.MutateTimeout == nil {
+		configs.MutateTimeout = &mutateTimeout
+	}
+
+	if configs.FailurePolicy == nil {
+		configs.FailurePolicy = &failurePolicy
+	}
+
+	if configs.WatchCerts == nil {
+		configs.WatchCerts = &watchCerts
+	}
+
+	if configs.CertPollPeriod == nil {
+		configs.CertPollPeriod = &certPollPeriod
+	}
+
+	if configs.CertCAFilePath == nil {
+		configs.CertCAFilePath = &certCAFilePath
+	}
+
+	if configs.CertExpiryWarnings == nil {
+		configs.CertExpiryWarnings = certExpiryWarnings
+	}
+
+	if configs.CertExpiryCritical == nil {
+		configs.CertExpiryCritical = &certExpiryCritical
+	}
+
+	if configs.SelfSignedValidity == nil {
+		configs.SelfSignedValidity = &selfSignedValidity
+	}
+
+	if configs.WriteSelfSigned == nil {
+		configs.WriteSelfSigned = &writeSelfSigned
+	}
+
+	if configs.SelfSignedCAFilePath == nil {
+		configs.SelfSignedCAFilePath = &selfSignedCAPath
+	}
+
+	if configs.ClientCAFilePath == nil {
+		configs.ClientCAFilePath = &clientCAFilePath
+	}
+
+	if configs.ClientAuth == nil {
+		configs.ClientAuth = &clientAuth
+	}
+
+	if configs.TLSProfile == nil {
+		configs.TLSProfile = &tlsProfile
+	}
+
+	if configs.EnableMetrics == nil {
+		configs.EnableMetrics = &enableMetrics
+	}
+
+	if configs.MetricsAddr == nil {
+		configs.MetricsAddr = &metricsAddr
+	}
+
 	return configs
 }
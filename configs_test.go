@@ -17,4 +17,19 @@ func TestSetDefault(t *testing.T) {
 	assert.Equal(t, *configs.MaxHeaderBytes, maxHeaderBytes)
 	assert.Equal(t, *configs.CertFilePath, certFilePath)
 	assert.Equal(t, *configs.KeyFilePath, keyFilePath)
+	assert.Equal(t, *configs.MutateTimeout, mutateTimeout)
+	assert.Equal(t, *configs.FailurePolicy, failurePolicy)
+	assert.Equal(t, *configs.WatchCerts, watchCerts)
+	assert.Equal(t, *configs.CertPollPeriod, certPollPeriod)
+	assert.Equal(t, *configs.CertCAFilePath, certCAFilePath)
+	assert.Equal(t, configs.CertExpiryWarnings, certExpiryWarnings)
+	assert.Equal(t, *configs.CertExpiryCritical, certExpiryCritical)
+	assert.Equal(t, *configs.SelfSignedValidity, selfSignedValidity)
+	assert.Equal(t, *configs.WriteSelfSigned, writeSelfSigned)
+	assert.Equal(t, *configs.SelfSignedCAFilePath, selfSignedCAPath)
+	assert.Equal(t, *configs.ClientCAFilePath, clientCAFilePath)
+	assert.Equal(t, *configs.ClientAuth, clientAuth)
+	assert.Equal(t, *configs.TLSProfile, tlsProfile)
+	assert.Equal(t, *configs.EnableMetrics, enableMetrics)
+	assert.Equal(t, *configs.MetricsAddr, metricsAddr)
 }
@@ -0,0 +1,176 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// The TypeMeta of the ConversionReview returned by the server.
+var conversionReviewTypeMeta = metav1.TypeMeta{
+	APIVersion: apiextensionsv1.SchemeGroupVersion.String(),
+	Kind:       "ConversionReview",
+}
+
+// The Converter interface is what is implemented to
+// convert custom resources between the versions of a CRD.
+type Converter interface {
+	// Converts the object to desiredAPIVersion.
+	// The returned object must have desiredAPIVersion as its apiVersion.
+	Convert(ctx context.Context, object *unstructured.Unstructured, desiredAPIVersion string) (*unstructured.Unstructured, error)
+}
+
+// Creates a MutatingWebhook server which serves the converter on /convert.
+// Mutators, validators and other converters can be added to it like any other server.
+func NewConversionWebhook(
+	converter Converter,
+	configs MutatingWebhookConfigs,
+) (AdmissionWebhook, error) {
+	mw, err := newMutatingWebhook(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mw.route("/convert", mw.handleConvert("convert", converter)); err != nil {
+		mw.Shutdown(context.Background())
+		return nil, err
+	}
+
+	return mw, nil
+}
+
+// Serves a converter on /convert/<name>.
+func (mw *mutatingWebhook) AddConverter(name string, converter Converter) error {
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return fmt.Errorf("invalid converter name %q: %s", name, strings.Join(errs, ", "))
+	}
+
+	return mw.route("/convert/"+name, mw.handleConvert("convert/"+name, converter))
+}
+
+// handleConvert is what wraps the named Converter and serves ConversionReviews.
+func (mw *mutatingWebhook) handleConvert(name string, converter Converter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mw.serveConvert(name, converter, w, r)
+	}
+}
+
+func (mw *mutatingWebhook) serveConvert(name string, converter Converter, w http.ResponseWriter, r *http.Request) {
+
+	// Conversions carry no admission request, so only their result is counted
+	if err := checkContentType(name, w, r); err != nil {
+		mw.metrics.observeRequest(name, nil, resultInvalid)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		klog.Errorf("%s: %v", name, err)
+		mw.metrics.observeRequest(name, nil, resultError)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "%s", internalServerError)
+		return
+	}
+	defer r.Body.Close()
+
+	conversionReview := apiextensionsv1.ConversionReview{}
+	if err := json.Unmarshal(body, &conversionReview); err != nil {
+		klog.Warningf("%s: invalid ConversionReview: %v", name, err)
+		mw.metrics.observeRequest(name, nil, resultInvalid)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "invalid ConversionReview: %s", err)
+		return
+	}
+
+	if conversionReview.Request == nil || conversionReview.Request.UID == "" {
+		klog.Warningf("%s: invalid ConversionReview: request or request.uid is missing", name)
+		mw.metrics.observeRequest(name, nil, resultInvalid)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "invalid ConversionReview: request or request.uid is missing")
+		return
+	}
+
+	ctx, cancel := mw.requestContext(r)
+	defer cancel()
+
+	response := apiextensionsv1.ConversionResponse{
+		UID: conversionReview.Request.UID,
+	}
+
+	converted, err := convertObjects(ctx, converter, conversionReview.Request)
+	if err != nil {
+		// A failure fails the whole review
+		klog.Errorf("%s: %v", name, err)
+		mw.metrics.observeRequest(name, nil, resultError)
+		response.Result = metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		}
+	} else {
+		mw.metrics.observeRequest(name, nil, resultAllowed)
+		response.ConvertedObjects = converted
+		response.Result = metav1.Status{
+			Status: metav1.StatusSuccess,
+		}
+	}
+
+	body, err = json.Marshal(apiextensionsv1.ConversionReview{
+		TypeMeta: conversionReviewTypeMeta,
+		Response: &response,
+	})
+	if err != nil {
+		klog.Errorf("%s: %v", name, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "%s", internalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// Converts each object of the request, stopping at the first failure.
+func convertObjects(ctx context.Context, converter Converter, request *apiextensionsv1.ConversionRequest) ([]runtime.RawExtension, error) {
+	converted := make([]runtime.RawExtension, 0, len(request.Objects))
+
+	for i, raw := range request.Objects {
+		object := &unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(raw.Raw); err != nil {
+			return nil, fmt.Errorf("unable to decode object %d: %w", i, err)
+		}
+
+		result, err := converter.Convert(ctx, object, request.DesiredAPIVersion)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert %s %s/%s: %w", object.GetKind(), object.GetNamespace(), object.GetName(), err)
+		}
+
+		if result == nil {
+			return nil, fmt.Errorf("converter returned no object for %s %s/%s", object.GetKind(), object.GetNamespace(), object.GetName())
+		}
+
+		if result.GetAPIVersion() != request.DesiredAPIVersion {
+			return nil, fmt.Errorf("converted %s %s/%s has apiVersion %q instead of %q", object.GetKind(), object.GetNamespace(), object.GetName(), result.GetAPIVersion(), request.DesiredAPIVersion)
+		}
+
+		convertedJSON, err := result.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		converted = append(converted, runtime.RawExtension{Raw: convertedJSON})
+	}
+
+	return converted, nil
+}
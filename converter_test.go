@@ -0,0 +1,168 @@
+package mutatingwebhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Converts between v1 (spec.size) and v2 (spec.replicas) of a test resource.
+type conv struct{}
+
+func (c *conv) Convert(ctx context.Context, object *unstructured.Unstructured, desiredAPIVersion string) (*unstructured.Unstructured, error) {
+	converted := object.DeepCopy()
+
+	switch desiredAPIVersion {
+	case "mutating.statcan.gc.ca/v2":
+		size, found, err := unstructured.NestedInt64(object.Object, "spec", "size")
+		if err != nil || !found {
+			return nil, fmt.Errorf("spec.size is missing")
+		}
+		unstructured.RemoveNestedField(converted.Object, "spec", "size")
+		unstructured.SetNestedField(converted.Object, size, "spec", "replicas")
+	default:
+		return nil, fmt.Errorf("unsupported version %s", desiredAPIVersion)
+	}
+
+	converted.SetAPIVersion(desiredAPIVersion)
+	return converted, nil
+}
+
+func getConversionReview(objects ...string) apiextensionsv1.ConversionReview {
+	review := apiextensionsv1.ConversionReview{
+		TypeMeta: conversionReviewTypeMeta,
+		Request: &apiextensionsv1.ConversionRequest{
+			UID:               "This is unique!",
+			DesiredAPIVersion: "mutating.statcan.gc.ca/v2",
+		},
+	}
+
+	for _, object := range objects {
+		review.Request.Objects = append(review.Request.Objects, runtime.RawExtension{Raw: []byte(object)})
+	}
+
+	return review
+}
+
+func TestConvertObjects(t *testing.T) {
+	review := getConversionReview(
+		`{"apiVersion": "mutating.statcan.gc.ca/v1", "kind": "ConverterTest", "metadata": {"name": "a"}, "spec": {"size": 1}}`,
+		`{"apiVersion": "mutating.statcan.gc.ca/v1", "kind": "ConverterTest", "metadata": {"name": "b"}, "spec": {"size": 2}}`,
+	)
+
+	converted, err := convertObjects(context.TODO(), &conv{}, review.Request)
+	assert.NoError(t, err)
+	assert.Len(t, converted, 2)
+	assert.JSONEq(t, `{"apiVersion": "mutating.statcan.gc.ca/v2", "kind": "ConverterTest", "metadata": {"name": "b"}, "spec": {"replicas": 2}}`, string(converted[1].Raw))
+
+	review = getConversionReview(
+		`{"apiVersion": "mutating.statcan.gc.ca/v1", "kind": "ConverterTest", "metadata": {"name": "a"}, "spec": {"size": 1}}`,
+		`{"apiVersion": "mutating.statcan.gc.ca/v1", "kind": "ConverterTest", "metadata": {"name": "b"}, "spec": {}}`,
+	)
+
+	_, err = convertObjects(context.TODO(), &conv{}, review.Request)
+	assert.EqualError(t, err, "unable to convert ConverterTest /b: spec.size is missing")
+}
+
+// Returns neither an object nor an error.
+type nilConv struct{}
+
+func (c *nilConv) Convert(ctx context.Context, object *unstructured.Unstructured, desiredAPIVersion string) (*unstructured.Unstructured, error) {
+	return nil, nil
+}
+
+func TestConvertObjectsNilResult(t *testing.T) {
+	review := getConversionReview(
+		`{"apiVersion": "mutating.statcan.gc.ca/v1", "kind": "ConverterTest", "metadata": {"name": "a"}, "spec": {"size": 1}}`,
+	)
+
+	_, err := convertObjects(context.TODO(), &nilConv{}, review.Request)
+	assert.EqualError(t, err, "converter returned no object for ConverterTest /a")
+}
+
+func TestCanConvert(t *testing.T) {
+
+	// Setup cert location for testing
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_certreload_test_%d", time.Now().Unix()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	mw, err := NewConversionWebhook(&conv{}, MutatingWebhookConfigs{
+		CertFilePath: &certFile,
+		KeyFilePath:  &keyFile,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	for _, test := range []struct {
+		object string
+		status string
+	}{
+		{`{"apiVersion": "mutating.statcan.gc.ca/v1", "kind": "ConverterTest", "metadata": {"name": "a"}, "spec": {"size": 1}}`, metav1.StatusSuccess},
+		{`{"apiVersion": "mutating.statcan.gc.ca/v1", "kind": "ConverterTest", "metadata": {"name": "a"}, "spec": {}}`, metav1.StatusFailure},
+	} {
+		requestBody, err := json.Marshal(getConversionReview(test.object))
+		assert.NoError(t, err)
+
+		resp, err := client.Post("https://localhost:8443/convert", "application/json", bytes.NewBuffer(requestBody))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+
+		reviewReturned := apiextensionsv1.ConversionReview{}
+		err = json.Unmarshal(bodyBytes, &reviewReturned)
+		assert.NoError(t, err)
+
+		assert.Equal(t, conversionReviewTypeMeta, reviewReturned.TypeMeta)
+		assert.Equal(t, "This is unique!", string(reviewReturned.Response.UID))
+		assert.Equal(t, test.status, reviewReturned.Response.Result.Status)
+		if test.status == metav1.StatusSuccess {
+			assert.Len(t, reviewReturned.Response.ConvertedObjects, 1)
+		}
+	}
+
+	// An empty review is rejected
+	resp, err := client.Post("https://localhost:8443/convert", "application/json", bytes.NewBufferString("{}"))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// So is a review which is not JSON
+	resp, err = client.Post("https://localhost:8443/convert", "text/plain", bytes.NewBufferString("{}"))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	metrics := mw.(*mutatingWebhook).metrics
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("convert", "", "", "", resultAllowed)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("convert", "", "", "", resultError)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.requests.WithLabelValues("convert", "", "", "", resultInvalid)))
+}
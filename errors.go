@@ -0,0 +1,60 @@
+package mutatingwebhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	v1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// A DenyError can be returned by a Mutator to reject the request.
+// The Code and Message are passed back to the API server in the
+// AdmissionResponse's Result, regardless of the FailurePolicy.
+type DenyError struct {
+	// The HTTP status code describing the denial, such as 403.
+	Code int32
+	// A human readable description of why the request was denied.
+	Message string
+}
+
+func (e *DenyError) Error() string {
+	return fmt.Sprintf("denied (%d): %s", e.Code, e.Message)
+}
+
+// Creates an error which denies the request with the given code and message.
+func Deny(code int32, message string) error {
+	return &DenyError{Code: code, Message: message}
+}
+
+// Builds the AdmissionResponse describing err.
+// A DenyError always denies the request. Any other error is reported
+// with the given code and allowed or denied according to the FailurePolicy.
+func (mw *mutatingWebhook) errorResponse(uid types.UID, code int32, err error) v1.AdmissionResponse {
+	response := v1.AdmissionResponse{
+		UID:     uid,
+		Allowed: *mw.configs.FailurePolicy == FailurePolicyIgnore,
+	}
+	message := err.Error()
+
+	var denial *DenyError
+	if errors.As(err, &denial) {
+		response.Allowed = false
+		code = denial.Code
+		message = denial.Message
+	}
+
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+
+	response.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    code,
+		Message: message,
+	}
+
+	return response
+}
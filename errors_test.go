@@ -0,0 +1,41 @@
+package mutatingwebhook
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestErrorResponseDeny(t *testing.T) {
+	ignore := FailurePolicyIgnore
+	mw := &mutatingWebhook{configs: setDefaults(MutatingWebhookConfigs{FailurePolicy: &ignore})}
+
+	response := mw.errorResponse("uid", http.StatusInternalServerError, fmt.Errorf("wrapped: %w", Deny(http.StatusForbidden, "not allowed")))
+
+	assert.False(t, response.Allowed)
+	assert.Equal(t, "uid", string(response.UID))
+	assert.Equal(t, metav1.StatusFailure, response.Result.Status)
+	assert.Equal(t, int32(http.StatusForbidden), response.Result.Code)
+	assert.Equal(t, "not allowed", response.Result.Message)
+}
+
+func TestErrorResponseFailurePolicy(t *testing.T) {
+	mw := &mutatingWebhook{configs: setDefaults(MutatingWebhookConfigs{})}
+
+	response := mw.errorResponse("uid", http.StatusInternalServerError, fmt.Errorf("boom"))
+
+	assert.False(t, response.Allowed)
+	assert.Equal(t, int32(http.StatusInternalServerError), response.Result.Code)
+	assert.Equal(t, "boom", response.Result.Message)
+
+	ignore := FailurePolicyIgnore
+	mw.configs.FailurePolicy = &ignore
+
+	response = mw.errorResponse("uid", http.StatusInternalServerError, fmt.Errorf("boom"))
+
+	assert.True(t, response.Allowed)
+	assert.Equal(t, int32(http.StatusInternalServerError), response.Result.Code)
+}
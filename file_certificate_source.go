@@ -0,0 +1,37 @@
+package mutatingwebhook
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+type fileCertificateSource struct {
+	certPath string
+	keyPath  string
+	*fileNotifier
+}
+
+// Creates a CertificateSource which reads the certificate and key from files.
+// When watch is set, the directories of the files are watched for changes.
+// When pollPeriod is not 0, the files are also checked for changes every pollPeriod,
+// for file systems which do not emit events.
+func NewFileCertificateSource(certPath, keyPath string, watch bool, pollPeriod time.Duration) (CertificateSource, error) {
+	notifier, err := newFileNotifier([]string{certPath, keyPath}, watch, pollPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileCertificateSource{
+		certPath:     certPath,
+		keyPath:      keyPath,
+		fileNotifier: notifier,
+	}, nil
+}
+
+func (source *fileCertificateSource) Certificate() (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(source.certPath, source.keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
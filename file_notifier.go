@@ -0,0 +1,220 @@
+package mutatingwebhook
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// How long to wait after the last file event before notifying,
+	// so that files written separately, such as a cert and key, are loaded together.
+	reloadDebounce = 50 * time.Millisecond
+	// How long to wait before watching a removed directory again.
+	rewatchInterval = time.Second
+)
+
+// Notifies of changes to a set of files.
+type fileNotifier struct {
+	paths       []string
+	fileWatcher *fsnotify.Watcher
+	// The directories containing the files.
+	// Watching them rather than the files survives the symlink swaps
+	// Kubernetes uses to update mounted Secrets.
+	watchDirs []string
+
+	changed     chan struct{}
+	reloadMu    sync.Mutex
+	reloadTimer *time.Timer
+	closed      bool
+	// Closed to stop polling the files.
+	stop chan struct{}
+}
+
+// Creates the struct notifying of changes to the files.
+// When watch is set, the directories of the files are watched for changes.
+// When pollPeriod is not 0, the files are also checked for changes every pollPeriod,
+// for file systems which do not emit events.
+func newFileNotifier(paths []string, watch bool, pollPeriod time.Duration) (*fileNotifier, error) {
+	result := &fileNotifier{
+		paths:   paths,
+		changed: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+
+	if watch {
+		if err := result.watch(); err != nil {
+			klog.Error(err)
+			return nil, err
+		}
+	}
+
+	if pollPeriod > 0 {
+		hash, err := result.hashFiles()
+		if err != nil {
+			result.Close()
+			return nil, err
+		}
+		go result.poll(pollPeriod, hash)
+	}
+
+	return result, nil
+}
+
+// Returns the channel receiving a value when the files may have changed.
+func (fn *fileNotifier) Changed() <-chan struct{} {
+	return fn.changed
+}
+
+// Watches the directories of the files for changes.
+func (fn *fileNotifier) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	fn.fileWatcher = watcher
+
+	seen := map[string]bool{}
+	for _, path := range fn.paths {
+		if dir := filepath.Dir(path); !seen[dir] {
+			seen[dir] = true
+			fn.watchDirs = append(fn.watchDirs, dir)
+		}
+	}
+
+	if err := fn.addWatches(); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				klog.V(4).Infof("TLS directory event: %s", event)
+				fn.scheduleNotify()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Error(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Checks the hash of the files every period,
+// notifying when it differs from the previous one.
+func (fn *fileNotifier) poll(period time.Duration, hash []byte) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fn.stop:
+			return
+		case <-ticker.C:
+			newHash, err := fn.hashFiles()
+			if err != nil {
+				klog.Warningf("Could not read TLS files: %v", err)
+				continue
+			}
+			if !bytes.Equal(hash, newHash) {
+				hash = newHash
+				fn.scheduleNotify()
+			}
+		}
+	}
+}
+
+// Returns the SHA-256 hash of the contents of the files.
+func (fn *fileNotifier) hashFiles() ([]byte, error) {
+	hash := sha256.New()
+
+	for _, path := range fn.paths {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(contents)
+	}
+
+	return hash.Sum(nil), nil
+}
+
+// Watches the directories of the files.
+// Adding a directory which is already watched has no effect.
+func (fn *fileNotifier) addWatches() error {
+	if fn.fileWatcher == nil {
+		return nil
+	}
+
+	for _, dir := range fn.watchDirs {
+		if err := fn.fileWatcher.Add(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Notifies once no file event has been seen for reloadDebounce.
+func (fn *fileNotifier) scheduleNotify() {
+	fn.schedule(reloadDebounce)
+}
+
+func (fn *fileNotifier) schedule(delay time.Duration) {
+	fn.reloadMu.Lock()
+	defer fn.reloadMu.Unlock()
+
+	if fn.closed {
+		return
+	}
+
+	if fn.reloadTimer != nil {
+		fn.reloadTimer.Stop()
+	}
+	fn.reloadTimer = time.AfterFunc(delay, func() {
+		// A watched directory may have been removed, in which case
+		// no more events arrive until it is recreated and watched again
+		if err := fn.addWatches(); err != nil {
+			klog.Warningf("Could not watch TLS directories, retrying: %v", err)
+			fn.schedule(rewatchInterval)
+			return
+		}
+
+		notifyChanged(fn.changed)
+	})
+}
+
+// Stops watching for changes.
+func (fn *fileNotifier) Close() error {
+	fn.reloadMu.Lock()
+	defer fn.reloadMu.Unlock()
+
+	if fn.closed {
+		return nil
+	}
+
+	fn.closed = true
+	close(fn.stop)
+	if fn.reloadTimer != nil {
+		fn.reloadTimer.Stop()
+	}
+
+	if fn.fileWatcher == nil {
+		return nil
+	}
+	return fn.fileWatcher.Close()
+}
@@ -4,11 +4,16 @@ go 1.15
 
 require (
 	github.com/fsnotify/fsnotify v1.4.9
-	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/hashicorp/go-multierror v1.1.1
-	github.com/stretchr/testify v1.6.1
-	golang.org/x/net v0.0.0-20201021035429-f5854403a974
-	k8s.io/api v0.18.0
-	k8s.io/apimachinery v0.18.0
+	github.com/prometheus/client_golang v1.11.1
+	github.com/stretchr/testify v1.7.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	golang.org/x/net v0.0.0-20201110031124-69a78807bb2b
+	k8s.io/api v0.19.16
+	k8s.io/apiextensions-apiserver v0.19.16
+	k8s.io/apimachinery v0.19.16
+	k8s.io/client-go v0.19.16
 	k8s.io/klog/v2 v2.8.0
 )
@@ -0,0 +1,374 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An OperationError describes which operation of a JSONPatch could not be applied.
+type OperationError struct {
+	// The index of the operation within the JSONPatch.
+	Index     int
+	Operation JSONPatchOperation
+	Err       error
+}
+
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("operation %d (%s %s): %v", e.Index, e.Operation.Op, e.Operation.Path, e.Err)
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// Applies the patch to the JSON document as described in RFC 6902.
+// The operations are applied in order and the first failure is
+// returned as an *OperationError.
+func (patch JSONPatch) Apply(doc []byte) ([]byte, error) {
+	result, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, operation := range patch {
+		if result, err = applyOperation(result, operation); err != nil {
+			return nil, &OperationError{Index: i, Operation: operation, Err: err}
+		}
+	}
+
+	return json.Marshal(result)
+}
+
+func applyOperation(doc interface{}, operation JSONPatchOperation) (interface{}, error) {
+	path, err := parsePointer(operation.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operation.Op {
+	case "add":
+		value, err := operationValue(operation)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case "remove":
+		return remove(doc, path)
+	case "replace":
+		value, err := operationValue(operation)
+		if err != nil {
+			return nil, err
+		}
+		return replace(doc, path, value)
+	case "copy", "move":
+		from, err := parsePointer(operation.From)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		if operation.Op == "copy" {
+			return add(doc, path, deepCopy(value))
+		}
+		if isProperPrefix(from, path) {
+			return nil, fmt.Errorf("cannot move %q into one of its children", operation.From)
+		}
+		if doc, err = remove(doc, from); err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return add(doc, path, value)
+	case "test":
+		value, err := operationValue(operation)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(actual, value) {
+			return nil, fmt.Errorf("value does not match")
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", operation.Op)
+	}
+}
+
+// Parses an array index. When allowEnd is set, "-" refers to
+// the position after the last element.
+func parseIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+
+	// Leading zeros and signs are not allowed
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if index > max {
+		return 0, fmt.Errorf("array index %d out of bounds", index)
+	}
+
+	return index, nil
+}
+
+// Returns the value referenced by path.
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			doc = value
+		case []interface{}:
+			index, err := parseIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[index]
+		default:
+			return nil, fmt.Errorf("cannot traverse %q of a scalar value", token)
+		}
+	}
+
+	return doc, nil
+}
+
+// Updates the child of doc named by the first token of path using fn,
+// returning the (possibly reallocated) doc.
+func update(doc interface{}, path []string, fn func(interface{}, []string) (interface{}, error)) (interface{}, error) {
+	token := path[0]
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, ok := container[token]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", token)
+		}
+		child, err := fn(child, path[1:])
+		if err != nil {
+			return nil, err
+		}
+		container[token] = child
+		return container, nil
+	case []interface{}:
+		index, err := parseIndex(token, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := fn(container[index], path[1:])
+		if err != nil {
+			return nil, err
+		}
+		container[index] = child
+		return container, nil
+	default:
+		return nil, fmt.Errorf("cannot traverse %q of a scalar value", token)
+	}
+}
+
+// Adds value at path, inserting into arrays.
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	if len(path) > 1 {
+		return update(doc, path, func(child interface{}, rest []string) (interface{}, error) {
+			return add(child, rest, value)
+		})
+	}
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		container[path[0]] = value
+		return container, nil
+	case []interface{}:
+		index, err := parseIndex(path[0], len(container), true)
+		if err != nil {
+			return nil, err
+		}
+		container = append(container, nil)
+		copy(container[index+1:], container[index:])
+		container[index] = value
+		return container, nil
+	default:
+		return nil, fmt.Errorf("cannot add %q to a scalar value", path[0])
+	}
+}
+
+// Removes the value at path.
+func remove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot remove the whole document")
+	}
+
+	if len(path) > 1 {
+		return update(doc, path, func(child interface{}, rest []string) (interface{}, error) {
+			return remove(child, rest)
+		})
+	}
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		if _, ok := container[path[0]]; !ok {
+			return nil, fmt.Errorf("member %q not found", path[0])
+		}
+		delete(container, path[0])
+		return container, nil
+	case []interface{}:
+		index, err := parseIndex(path[0], len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		return append(container[:index], container[index+1:]...), nil
+	default:
+		return nil, fmt.Errorf("cannot remove %q from a scalar value", path[0])
+	}
+}
+
+// Replaces the existing value at path.
+func replace(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	if len(path) > 1 {
+		return update(doc, path, func(child interface{}, rest []string) (interface{}, error) {
+			return replace(child, rest, value)
+		})
+	}
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		if _, ok := container[path[0]]; !ok {
+			return nil, fmt.Errorf("member %q not found", path[0])
+		}
+		container[path[0]] = value
+		return container, nil
+	case []interface{}:
+		index, err := parseIndex(path[0], len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		container[index] = value
+		return container, nil
+	default:
+		return nil, fmt.Errorf("cannot replace %q of a scalar value", path[0])
+	}
+}
+
+// Whether prefix is a proper prefix of path.
+func isProperPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the value of an add, replace or test operation, which is required.
+func operationValue(operation JSONPatchOperation) (interface{}, error) {
+	if operation.missingValue {
+		return nil, fmt.Errorf("missing value")
+	}
+	return normalize(operation.Value)
+}
+
+// Converts an operation's value into the generic form of a decoded JSON document.
+func normalize(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(encoded)
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			result[key] = deepCopy(child)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			result[i] = deepCopy(child)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// Compares two decoded JSON values, treating numbers by their numeric value.
+func equal(a, b interface{}) bool {
+	switch aValue := a.(type) {
+	case map[string]interface{}:
+		bValue, ok := b.(map[string]interface{})
+		if !ok || len(aValue) != len(bValue) {
+			return false
+		}
+		for key, child := range aValue {
+			other, ok := bValue[key]
+			if !ok || !equal(child, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bValue, ok := b.([]interface{})
+		if !ok || len(aValue) != len(bValue) {
+			return false
+		}
+		for i := range aValue {
+			if !equal(aValue[i], bValue[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bValue, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if aValue == bValue {
+			return true
+		}
+		aFloat, aErr := aValue.Float64()
+		bFloat, bErr := bValue.Float64()
+		return aErr == nil && bErr == nil && aFloat == bFloat
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,225 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Examples from RFC 6902, Appendix A
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    JSONPatch
+		expected string
+	}{
+		{
+			name:     "add an object member",
+			doc:      `{"foo": "bar"}`,
+			patch:    JSONPatch{{Op: "add", Path: "/baz", Value: "qux"}},
+			expected: `{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			name:     "add an array element",
+			doc:      `{"foo": ["bar", "baz"]}`,
+			patch:    JSONPatch{{Op: "add", Path: "/foo/1", Value: "qux"}},
+			expected: `{"foo": ["bar", "qux", "baz"]}`,
+		},
+		{
+			name:     "append an array element",
+			doc:      `{"foo": ["bar"]}`,
+			patch:    JSONPatch{{Op: "add", Path: "/foo/-", Value: []string{"abc", "def"}}},
+			expected: `{"foo": ["bar", ["abc", "def"]]}`,
+		},
+		{
+			name:     "remove an object member",
+			doc:      `{"baz": "qux", "foo": "bar"}`,
+			patch:    JSONPatch{{Op: "remove", Path: "/baz"}},
+			expected: `{"foo": "bar"}`,
+		},
+		{
+			name:     "remove an array element",
+			doc:      `{"foo": ["bar", "qux", "baz"]}`,
+			patch:    JSONPatch{{Op: "remove", Path: "/foo/1"}},
+			expected: `{"foo": ["bar", "baz"]}`,
+		},
+		{
+			name:     "replace a value",
+			doc:      `{"baz": "qux", "foo": "bar"}`,
+			patch:    JSONPatch{{Op: "replace", Path: "/baz", Value: "boo"}},
+			expected: `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			name: "move a value",
+			doc:  `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch: JSONPatch{
+				{Op: "move", From: "/foo/waldo", Path: "/qux/thud"},
+			},
+			expected: `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{
+			name:     "move an array element",
+			doc:      `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch:    JSONPatch{{Op: "move", From: "/foo/1", Path: "/foo/3"}},
+			expected: `{"foo": ["all", "cows", "eat", "grass"]}`,
+		},
+		{
+			name:     "copy a value",
+			doc:      `{"foo": {"bar": [1]}}`,
+			patch:    JSONPatch{{Op: "copy", From: "/foo/bar", Path: "/baz"}},
+			expected: `{"foo": {"bar": [1]}, "baz": [1]}`,
+		},
+		{
+			name: "test a value",
+			doc:  `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			patch: JSONPatch{
+				{Op: "test", Path: "/baz", Value: "qux"},
+				{Op: "test", Path: "/foo/1", Value: 2.0},
+			},
+			expected: `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+		},
+		{
+			name:     "add a nested member object",
+			doc:      `{"foo": "bar"}`,
+			patch:    JSONPatch{{Op: "add", Path: "/child", Value: map[string]interface{}{"grandchild": map[string]interface{}{}}}},
+			expected: `{"foo": "bar", "child": {"grandchild": {}}}`,
+		},
+		{
+			name: "escaped pointers",
+			doc:  `{"/": 9, "~1": 10}`,
+			patch: JSONPatch{
+				{Op: "test", Path: "/~01", Value: 10},
+				{Op: "replace", Path: "/~1", Value: 11},
+			},
+			expected: `{"/": 11, "~1": 10}`,
+		},
+		{
+			name:     "replace the whole document",
+			doc:      `{"foo": "bar"}`,
+			patch:    JSONPatch{{Op: "replace", Path: "", Value: []int{1}}},
+			expected: `[1]`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := test.patch.Apply([]byte(test.doc))
+			assert.NoError(t, err)
+			assert.JSONEq(t, test.expected, string(result))
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch JSONPatch
+		index int
+	}{
+		{
+			name:  "test a value",
+			doc:   `{"baz": "qux"}`,
+			patch: JSONPatch{{Op: "test", Path: "/baz", Value: "bar"}},
+		},
+		{
+			name:  "add to a nonexistent target",
+			doc:   `{"foo": "bar"}`,
+			patch: JSONPatch{{Op: "add", Path: "/baz/bat", Value: "qux"}},
+		},
+		{
+			name: "remove a missing member",
+			doc:  `{"foo": "bar"}`,
+			patch: JSONPatch{
+				{Op: "remove", Path: "/foo"},
+				{Op: "remove", Path: "/foo"},
+			},
+			index: 1,
+		},
+		{
+			name:  "array index out of bounds",
+			doc:   `{"foo": [1]}`,
+			patch: JSONPatch{{Op: "add", Path: "/foo/2", Value: 2}},
+		},
+		{
+			name:  "array index with leading zero",
+			doc:   `{"foo": [1, 2]}`,
+			patch: JSONPatch{{Op: "replace", Path: "/foo/01", Value: 2}},
+		},
+		{
+			name:  "move into a child",
+			doc:   `{"foo": {"bar": 1}}`,
+			patch: JSONPatch{{Op: "move", From: "/foo", Path: "/foo/bar/baz"}},
+		},
+		{
+			name:  "unknown operation",
+			doc:   `{}`,
+			patch: JSONPatch{{Op: "merge", Path: "/foo"}},
+		},
+		{
+			name:  "invalid pointer",
+			doc:   `{}`,
+			patch: JSONPatch{{Op: "add", Path: "foo", Value: 1}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.patch.Apply([]byte(test.doc))
+
+			var operationError *OperationError
+			assert.True(t, errors.As(err, &operationError))
+			assert.Equal(t, test.index, operationError.Index)
+		})
+	}
+}
+
+func TestApplyDecodedPatch(t *testing.T) {
+	patch := JSONPatch{}
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"op": "add", "path": "/big", "value": 9007199254740993},
+		{"op": "add", "path": "/nested", "value": {"big": [18446744073709551615]}},
+		{"op": "replace", "path": "/null", "value": null},
+		{"op": "test", "path": "/big", "value": 9007199254740993}
+	]`), &patch))
+
+	result, err := patch.Apply([]byte(`{"null": 1}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"big": 9007199254740993, "nested": {"big": [18446744073709551615]}, "null": null}`, string(result))
+	assert.Contains(t, string(result), "9007199254740993")
+}
+
+func TestApplyMissingValue(t *testing.T) {
+	for _, op := range []string{"add", "replace", "test"} {
+		patch := JSONPatch{}
+		assert.NoError(t, json.Unmarshal([]byte(`[{"op": "`+op+`", "path": "/x"}]`), &patch))
+
+		_, err := patch.Apply([]byte(`{"x": null}`))
+
+		var operationError *OperationError
+		assert.True(t, errors.As(err, &operationError), op)
+		assert.EqualError(t, err, "operation 0 ("+op+" /x): missing value")
+	}
+
+	// remove, copy and move take no value
+	patch := JSONPatch{}
+	assert.NoError(t, json.Unmarshal([]byte(`[{"op": "copy", "from": "/x", "path": "/y"}, {"op": "remove", "path": "/x"}]`), &patch))
+	result, err := patch.Apply([]byte(`{"x": 1}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"y": 1}`, string(result))
+}
+
+func TestApplyCreatedPatch(t *testing.T) {
+	original := []byte(`{"a": 1, "b": {"c": "d", "e/f": [1, 2, 3]}, "g": true, "h": "i"}`)
+	modified := []byte(`{"a": 1, "b": {"c": "changed", "e/f": [1, 4], "~new": null}, "h": ["i"]}`)
+
+	patch, err := CreatePatch(original, modified)
+	assert.NoError(t, err)
+
+	result, err := patch.Apply(original)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(modified), string(result))
+}
@@ -0,0 +1,109 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+)
+
+// A Builder assembles a JSONPatch one operation at a time.
+// The first error encountered is returned by Build.
+type Builder struct {
+	patch JSONPatch
+	// Pointers added by this Builder, used by AddWithParents.
+	added map[string]bool
+	err   error
+}
+
+// Creates an empty Builder.
+func New() *Builder {
+	return &Builder{added: map[string]bool{}}
+}
+
+func (b *Builder) append(operation JSONPatchOperation) *Builder {
+	b.patch = append(b.patch, operation)
+	return b
+}
+
+// Adds value at path.
+func (b *Builder) Add(path Pointer, value interface{}) *Builder {
+	b.added[path.String()] = true
+	return b.append(JSONPatchOperation{Op: "add", Path: path.String(), Value: jsonValue(value)})
+}
+
+// Adds value at path, first adding empty containers for any of its parents
+// which are missing from doc: an array when the token following the parent
+// is "-", an object otherwise, since numeric tokens are also valid member names.
+// doc is either a JSON document or a value which marshals to one, such as the
+// object being mutated.
+func (b *Builder) AddWithParents(doc interface{}, path Pointer, value interface{}) *Builder {
+	decoded, err := decodeDocument(doc)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return b
+	}
+
+	for i := 1; i < len(path); i++ {
+		parent := path[:i]
+		if b.added[parent.String()] {
+			continue
+		}
+		if existing, err := get(decoded, parent); err == nil && existing != nil {
+			continue
+		}
+		if path[i] == "-" {
+			b.Add(NewPointer(parent...), []interface{}{})
+		} else {
+			b.Add(NewPointer(parent...), map[string]interface{}{})
+		}
+	}
+
+	return b.Add(path, value)
+}
+
+// Removes the value at path.
+func (b *Builder) Remove(path Pointer) *Builder {
+	return b.append(JSONPatchOperation{Op: "remove", Path: path.String()})
+}
+
+// Replaces the value at path.
+func (b *Builder) Replace(path Pointer, value interface{}) *Builder {
+	return b.append(JSONPatchOperation{Op: "replace", Path: path.String(), Value: jsonValue(value)})
+}
+
+// Copies the value at from to path.
+func (b *Builder) Copy(from, path Pointer) *Builder {
+	b.added[path.String()] = true
+	return b.append(JSONPatchOperation{Op: "copy", From: from.String(), Path: path.String()})
+}
+
+// Moves the value at from to path.
+func (b *Builder) Move(from, path Pointer) *Builder {
+	b.added[path.String()] = true
+	return b.append(JSONPatchOperation{Op: "move", From: from.String(), Path: path.String()})
+}
+
+// Tests that the value at path equals value.
+func (b *Builder) Test(path Pointer, value interface{}) *Builder {
+	return b.append(JSONPatchOperation{Op: "test", Path: path.String(), Value: jsonValue(value)})
+}
+
+// Returns the assembled JSONPatch.
+func (b *Builder) Build() (JSONPatch, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return append(JSONPatch{}, b.patch...), nil
+}
+
+// Decodes a JSON document given either as bytes or as a value to marshal.
+func decodeDocument(doc interface{}) (interface{}, error) {
+	switch v := doc.(type) {
+	case []byte:
+		return decode(v)
+	case json.RawMessage:
+		return decode(v)
+	default:
+		return normalize(v)
+	}
+}
@@ -0,0 +1,155 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPointer(t *testing.T) {
+	pointer := NewPointer("metadata", "annotations", "example.com/injected~")
+	assert.Equal(t, "/metadata/annotations/example.com~1injected~0", pointer.String())
+	assert.Equal(t, "/metadata/labels", NewPointer("metadata").Append("labels").String())
+	assert.Equal(t, "", NewPointer().String())
+
+	parsed, err := ParsePointer(pointer.String())
+	assert.NoError(t, err)
+	assert.Equal(t, pointer, parsed)
+
+	_, err = ParsePointer("metadata")
+	assert.Error(t, err)
+}
+
+func TestBuilder(t *testing.T) {
+	patch, err := New().
+		Test(NewPointer("spec", "containers", "0", "name"), "mutating-webhook-test").
+		Replace(NewPointer("spec", "containers", "0", "image"), "otherimage").
+		Add(NewPointer("spec", "containers", "0", "args"), []string{"--verbose"}).
+		Copy(NewPointer("spec", "containers", "0"), NewPointer("spec", "containers", "-")).
+		Move(NewPointer("spec", "containers", "1", "args"), NewPointer("spec", "containers", "1", "command")).
+		Remove(NewPointer("spec", "containers", "0", "args")).
+		Build()
+	assert.NoError(t, err)
+
+	result, err := patch.Apply([]byte(`{"spec": {"containers": [{"name": "mutating-webhook-test", "image": "testimage"}]}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec": {"containers": [
+		{"name": "mutating-webhook-test", "image": "otherimage"},
+		{"name": "mutating-webhook-test", "image": "otherimage", "command": ["--verbose"]}
+	]}}`, string(result))
+}
+
+func TestAddWithParents(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	annotations := NewPointer("metadata", "annotations")
+	patch, err := New().
+		AddWithParents(pod, annotations.Append("example.com/injected"), "true").
+		AddWithParents(pod, annotations.Append("example.com/version"), "1").
+		AddWithParents(pod, NewPointer("metadata", "name"), "renamed").
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, JSONPatch{
+		{Op: "add", Path: "/metadata/annotations", Value: map[string]interface{}{}},
+		{Op: "add", Path: "/metadata/annotations/example.com~1injected", Value: "true"},
+		{Op: "add", Path: "/metadata/annotations/example.com~1version", Value: "1"},
+		{Op: "add", Path: "/metadata/name", Value: "renamed"},
+	}, patch)
+
+	// Existing parents are left alone
+	pod.Annotations = map[string]string{"existing": "annotation"}
+	patch, err = New().
+		AddWithParents(pod, annotations.Append("example.com/injected"), "true").
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, JSONPatch{
+		{Op: "add", Path: "/metadata/annotations/example.com~1injected", Value: "true"},
+	}, patch)
+}
+
+func TestAddWithParentsArrays(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app"}},
+		},
+	}
+
+	env := NewPointer("spec", "containers", "0", "env")
+	patch, err := New().
+		AddWithParents(pod, env.Append("-"), corev1.EnvVar{Name: "INJECTED", Value: "true"}).
+		AddWithParents(pod, NewPointer("spec", "initContainers", "-"), corev1.Container{Name: "init"}).
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "/spec/containers/0/env", patch[0].Path)
+	assert.Equal(t, []interface{}{}, patch[0].Value)
+	assert.Equal(t, "/spec/initContainers", patch[2].Path)
+	assert.Equal(t, []interface{}{}, patch[2].Value)
+
+	document, err := json.Marshal(pod)
+	assert.NoError(t, err)
+
+	result, err := patch.Apply(document)
+	assert.NoError(t, err)
+
+	patched := corev1.Pod{}
+	assert.NoError(t, json.Unmarshal(result, &patched))
+	assert.Equal(t, []corev1.EnvVar{{Name: "INJECTED", Value: "true"}}, patched.Spec.Containers[0].Env)
+	assert.Equal(t, []corev1.Container{{Name: "init"}}, patched.Spec.InitContainers)
+}
+
+func TestAddWithParentsNumericKey(t *testing.T) {
+	pod := &corev1.Pod{}
+
+	// Numeric tokens are member names of a missing parent, not array indices
+	labels := NewPointer("metadata", "labels")
+	patch, err := New().
+		AddWithParents(pod, labels.Append("2024"), "x").
+		Build()
+	assert.NoError(t, err)
+	assert.Equal(t, JSONPatch{
+		{Op: "add", Path: "/metadata/labels", Value: map[string]interface{}{}},
+		{Op: "add", Path: "/metadata/labels/2024", Value: "x"},
+	}, patch)
+
+	result, err := patch.Apply([]byte(`{"metadata": {}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata": {"labels": {"2024": "x"}}}`, string(result))
+}
+
+func TestBuilderNullValues(t *testing.T) {
+	patch, err := New().
+		Test(NewPointer("a"), nil).
+		Replace(NewPointer("a"), "b").
+		Add(NewPointer("c"), nil).
+		Replace(NewPointer("a"), nil).
+		Build()
+	assert.NoError(t, err)
+
+	patchJSON, err := json.Marshal(patch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op": "test", "path": "/a", "value": null},
+		{"op": "replace", "path": "/a", "value": "b"},
+		{"op": "add", "path": "/c", "value": null},
+		{"op": "replace", "path": "/a", "value": null}
+	]`, string(patchJSON))
+
+	// The patch is still valid once sent and decoded
+	decoded := JSONPatch{}
+	assert.NoError(t, json.Unmarshal(patchJSON, &decoded))
+	result, err := decoded.Apply([]byte(`{"a": null}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a": null, "c": null}`, string(result))
+}
+
+func TestAddWithParentsInvalidDocument(t *testing.T) {
+	_, err := New().AddWithParents([]byte(`{`), NewPointer("metadata", "name"), "test").Build()
+	assert.Error(t, err)
+}
@@ -0,0 +1,159 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Used as the value of operations which set a JSON null,
+// since a nil Value is omitted when marshalled.
+var jsonNull = json.RawMessage("null")
+
+// Computes the JSONPatch which transforms the original JSON document into the modified one.
+func CreatePatch(original, modified []byte) (JSONPatch, error) {
+	originalDoc, err := decode(original)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiedDoc, err := decode(modified)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff(JSONPatch{}, "", originalDoc, modifiedDoc), nil
+}
+
+// Computes the JSONPatch which transforms the original object into the modified one.
+func CreateObjectPatch(original, modified runtime.Object) (JSONPatch, error) {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiedJSON, err := json.Marshal(modified)
+	if err != nil {
+		return nil, err
+	}
+
+	return CreatePatch(originalJSON, modifiedJSON)
+}
+
+// Decodes a JSON document, keeping numbers as json.Number so they are not altered.
+func decode(doc []byte) (interface{}, error) {
+	var result interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Appends to patch the operations turning original into modified at path.
+func diff(patch JSONPatch, path string, original, modified interface{}) JSONPatch {
+	if reflect.DeepEqual(original, modified) {
+		return patch
+	}
+
+	switch originalValue := original.(type) {
+	case map[string]interface{}:
+		if modifiedValue, ok := modified.(map[string]interface{}); ok {
+			return diffObjects(patch, path, originalValue, modifiedValue)
+		}
+	case []interface{}:
+		if modifiedValue, ok := modified.([]interface{}); ok {
+			return diffArrays(patch, path, originalValue, modifiedValue)
+		}
+	}
+
+	return append(patch, JSONPatchOperation{Op: "replace", Path: path, Value: jsonValue(modified)})
+}
+
+func diffObjects(patch JSONPatch, path string, original, modified map[string]interface{}) JSONPatch {
+	// Sort the keys so that the patch is deterministic
+	keys := make([]string, 0, len(original)+len(modified))
+	for key := range original {
+		keys = append(keys, key)
+	}
+	for key := range modified {
+		if _, ok := original[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := path + "/" + escape(key)
+		originalValue, inOriginal := original[key]
+		modifiedValue, inModified := modified[key]
+
+		switch {
+		case !inModified:
+			patch = append(patch, JSONPatchOperation{Op: "remove", Path: keyPath})
+		case !inOriginal:
+			patch = append(patch, JSONPatchOperation{Op: "add", Path: keyPath, Value: jsonValue(modifiedValue)})
+		default:
+			patch = diff(patch, keyPath, originalValue, modifiedValue)
+		}
+	}
+
+	return patch
+}
+
+// Elements common to the start and end of both arrays are left alone,
+// so that a single insertion or removal is a single operation.
+func diffArrays(patch JSONPatch, path string, original, modified []interface{}) JSONPatch {
+	shortest := len(original)
+	if len(modified) < shortest {
+		shortest = len(modified)
+	}
+
+	prefix := 0
+	for prefix < shortest && reflect.DeepEqual(original[prefix], modified[prefix]) {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < shortest-prefix && reflect.DeepEqual(original[len(original)-1-suffix], modified[len(modified)-1-suffix]) {
+		suffix++
+	}
+
+	original = original[prefix : len(original)-suffix]
+	modified = modified[prefix : len(modified)-suffix]
+
+	common := len(original)
+	if len(modified) < common {
+		common = len(modified)
+	}
+
+	for i := 0; i < common; i++ {
+		patch = diff(patch, path+"/"+strconv.Itoa(prefix+i), original[i], modified[i])
+	}
+
+	for i := common; i < len(modified); i++ {
+		patch = append(patch, JSONPatchOperation{Op: "add", Path: path + "/" + strconv.Itoa(prefix+i), Value: jsonValue(modified[i])})
+	}
+
+	// Remove from the end so that the indices stay valid
+	for i := len(original) - 1; i >= common; i-- {
+		patch = append(patch, JSONPatchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(prefix+i)})
+	}
+
+	return patch
+}
+
+// Returns the value to place in an operation.
+func jsonValue(v interface{}) interface{} {
+	if v == nil {
+		return jsonNull
+	}
+	return v
+}
@@ -0,0 +1,102 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreatePatch(t *testing.T) {
+	original := []byte(`{"a": 1, "b": {"c": "d", "e/f": [1, 2, 3]}, "g": true, "h": "i"}`)
+	modified := []byte(`{"a": 1, "b": {"c": "changed", "e/f": [1, 4], "~new": null}, "h": ["i"]}`)
+
+	patch, err := CreatePatch(original, modified)
+	assert.NoError(t, err)
+
+	patchJSON, err := json.Marshal(patch)
+	assert.NoError(t, err)
+
+	assert.JSONEq(t, `[
+		{"op": "replace", "path": "/b/c", "value": "changed"},
+		{"op": "replace", "path": "/b/e~1f/1", "value": 4},
+		{"op": "remove", "path": "/b/e~1f/2"},
+		{"op": "add", "path": "/b/~0new", "value": null},
+		{"op": "remove", "path": "/g"},
+		{"op": "replace", "path": "/h", "value": ["i"]}
+	]`, string(patchJSON))
+}
+
+func TestCreatePatchArrays(t *testing.T) {
+	tests := []struct {
+		original, modified, patch string
+	}{
+		{`[1, 2, 3]`, `[2, 3]`, `[{"op": "remove", "path": "/0"}]`},
+		{`[1, 2, 3]`, `[1, 3]`, `[{"op": "remove", "path": "/1"}]`},
+		{`[1, 2, 3]`, `[0, 1, 2, 3]`, `[{"op": "add", "path": "/0", "value": 0}]`},
+		{`[1, 2, 3]`, `[1, 2, 2.5, 3]`, `[{"op": "add", "path": "/2", "value": 2.5}]`},
+		{`[1, 1, 1]`, `[1, 1]`, `[{"op": "remove", "path": "/2"}]`},
+		{`[1, 2, 3]`, `[1, 4, 5, 3]`, `[{"op": "replace", "path": "/1", "value": 4}, {"op": "add", "path": "/2", "value": 5}]`},
+	}
+
+	for _, test := range tests {
+		patch, err := CreatePatch([]byte(test.original), []byte(test.modified))
+		assert.NoError(t, err)
+
+		patchJSON, err := json.Marshal(patch)
+		assert.NoError(t, err)
+		assert.JSONEq(t, test.patch, string(patchJSON), "%s to %s", test.original, test.modified)
+
+		patched, err := patch.Apply([]byte(test.original))
+		assert.NoError(t, err)
+		assert.JSONEq(t, test.modified, string(patched))
+	}
+}
+
+func TestCreatePatchRemoveFirstContainer(t *testing.T) {
+	original := []byte(`{"spec": {"containers": [{"name": "sidecar"}, {"name": "app"}, {"name": "proxy"}]}}`)
+	modified := []byte(`{"spec": {"containers": [{"name": "app"}, {"name": "proxy"}]}}`)
+
+	patch, err := CreatePatch(original, modified)
+	assert.NoError(t, err)
+	assert.Equal(t, JSONPatch{{Op: "remove", Path: "/spec/containers/0"}}, patch)
+}
+
+func TestCreatePatchNoChanges(t *testing.T) {
+	patch, err := CreatePatch([]byte(`{"a": [1, {"b": 2}]}`), []byte(`{"a": [1, {"b": 2}]}`))
+	assert.NoError(t, err)
+	assert.Empty(t, patch)
+}
+
+func TestCreatePatchInvalidJSON(t *testing.T) {
+	_, err := CreatePatch([]byte(`{`), []byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestCreateObjectPatch(t *testing.T) {
+	original := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "mutating-webhook-test",
+					Image: "testimage",
+				},
+			},
+		},
+	}
+
+	modified := original.DeepCopy()
+	modified.ObjectMeta = metav1.ObjectMeta{
+		Labels: map[string]string{"app.kubernetes.io/name": "test"},
+	}
+	modified.Spec.Containers[0].Image = "otherimage"
+
+	patch, err := CreateObjectPatch(original, modified)
+	assert.NoError(t, err)
+	assert.Equal(t, JSONPatch{
+		{Op: "add", Path: "/metadata/labels", Value: map[string]interface{}{"app.kubernetes.io/name": "test"}},
+		{Op: "replace", Path: "/spec/containers/0/image", Value: "otherimage"},
+	}, patch)
+}
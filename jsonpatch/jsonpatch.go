@@ -1,5 +1,9 @@
 package jsonpatch
 
+import (
+	"encoding/json"
+)
+
 // Represents a JSON patch
 type JSONPatchOperation struct {
 	// The Operation to be applied.
@@ -9,6 +13,45 @@ type JSONPatchOperation struct {
 	// The JSON Pointer to the value on which to operate.
 	Path  string      `json:"path"`
 	Value interface{} `json:"value,omitempty"`
+	// The JSON Pointer to the value to copy or move.
+	// Only used by the copy and move operations.
+	From string `json:"from,omitempty"`
+	// Set when the operation was decoded from JSON without a value member,
+	// which the add, replace and test operations require.
+	missingValue bool
+}
+
+// Decodes the operation, keeping the numbers of its value as json.Number
+// so that they are not altered, and noting whether the value was present.
+func (operation *JSONPatchOperation) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Op    string           `json:"op"`
+		Path  string           `json:"path"`
+		Value *json.RawMessage `json:"value"`
+		From  string           `json:"from"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*operation = JSONPatchOperation{Op: fields.Op, Path: fields.Path, From: fields.From}
+	if fields.Value == nil {
+		// A null value is also decoded as nil, so check the member itself
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(data, &members); err != nil {
+			return err
+		}
+		_, present := members["value"]
+		operation.missingValue = !present
+		return nil
+	}
+
+	value, err := decode(*fields.Value)
+	if err != nil {
+		return err
+	}
+	operation.Value = value
+	return nil
 }
 
 // A JSONPatch is a collection of JSONPatchOperations
@@ -0,0 +1,71 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Pointer is a JSON Pointer (RFC 6901) made of unescaped reference tokens.
+// Tokens containing ~ or / are escaped when the Pointer is converted to a string.
+type Pointer []string
+
+// Creates a Pointer from its unescaped segments.
+// NewPointer("metadata", "annotations", "example.com/injected") refers to
+// /metadata/annotations/example.com~1injected.
+func NewPointer(segments ...string) Pointer {
+	return append(Pointer{}, segments...)
+}
+
+// Parses an escaped JSON Pointer such as /metadata/annotations/example.com~1injected.
+func ParsePointer(pointer string) (Pointer, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return Pointer(tokens), nil
+}
+
+// Returns a new Pointer with the segments appended.
+func (p Pointer) Append(segments ...string) Pointer {
+	result := make(Pointer, 0, len(p)+len(segments))
+	result = append(result, p...)
+	return append(result, segments...)
+}
+
+// Returns the escaped representation of the Pointer.
+func (p Pointer) String() string {
+	var builder strings.Builder
+	for _, token := range p {
+		builder.WriteString("/")
+		builder.WriteString(escape(token))
+	}
+	return builder.String()
+}
+
+// Splits a JSON Pointer (RFC 6901) into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = unescape(token)
+	}
+
+	return tokens, nil
+}
+
+// Unescapes a reference token as described in RFC 6901.
+func unescape(token string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+}
+
+// Escapes a reference token as described in RFC 6901.
+func escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
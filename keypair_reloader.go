@@ -2,9 +2,12 @@ package mutatingwebhook
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math/big"
 	"sync"
+	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"k8s.io/klog/v2"
 )
 
@@ -12,85 +15,229 @@ import (
 // https://github.com/robustirc/bridge/blob/6b0e8ab3736b2b847b70a924971df0d0024d6b79/tlsutil/tlsutil.go
 // https://stackoverflow.com/questions/37473201/is-there-a-way-to-update-the-tls-certificates-in-a-net-http-server-without-any-d/40883377#40883377
 
+const (
+	// How often to check whether the certificate is about to expire.
+	expiryCheckPeriod = time.Minute
+)
+
+// Describes the certificate being served.
+type certificateInfo struct {
+	NotAfter     time.Time
+	SerialNumber *big.Int
+	Subject      string
+}
+
+// Serves the certificate of a CertificateSource, reloading it when it changes.
 type keypairReloader struct {
-	certMu      sync.RWMutex
-	cert        *tls.Certificate
-	fileWatcher *fsnotify.Watcher
-	certPath    string
-	keyPath     string
+	certMu sync.RWMutex
+	cert   *tls.Certificate
+	source CertificateSource
+	// What a new certificate must satisfy to replace the current one.
+	requirements certificateRequirements
+	// Where reloads are reported, if anywhere, and the server name they are reported for.
+	metrics    *webhookMetrics
+	serverName string
+
+	closeMu sync.Mutex
+	closed  bool
+	// Closed to stop reloading and checking the expiry.
+	stop chan struct{}
+
+	// The remaining validities below which a warning is logged,
+	// and those already logged for the current certificate.
+	expiryWarnings []time.Duration
+	expiryMu       sync.Mutex
+	warnedSerial   string
+	warned         map[time.Duration]bool
 }
 
 // Creates the struct that allows for the management of the certificate reloading.
-func newKeypairReloader(certPath, keyPath string) (*keypairReloader, error) {
+// The certificate is reloaded from the source whenever it reports a change.
+// The source is closed along with the keypairReloader, or if it cannot be created.
+func newKeypairReloader(source CertificateSource, configs MutatingWebhookConfigs) (*keypairReloader, error) {
 	result := &keypairReloader{
-		certPath: certPath,
-		keyPath:  keyPath,
+		source: source,
+		requirements: certificateRequirements{
+			dnsNames:   configs.CertDNSNames,
+			caFilePath: *configs.CertCAFilePath,
+		},
+		stop:           make(chan struct{}),
+		expiryWarnings: configs.CertExpiryWarnings,
 	}
 
-	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	cert, err := result.load()
 	if err != nil {
+		source.Close()
 		return nil, err
 	}
 
-	result.cert = &cert
+	result.cert = cert
 
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		klog.Error(err)
-		return nil, err
+	if changed := source.Changed(); changed != nil {
+		go result.watch(changed)
 	}
 
-	result.fileWatcher = watcher
-	err = result.fileWatcher.Add(certPath)
-	if err != nil {
-		klog.Error(err)
-		return nil, err
+	result.checkExpiry(time.Now())
+	go result.monitorExpiry()
+
+	return result, nil
+}
+
+// Reloads the certificate whenever the source reports a change.
+func (kpr *keypairReloader) watch(changed <-chan struct{}) {
+	for {
+		select {
+		case <-kpr.stop:
+			return
+		case _, ok := <-changed:
+			if !ok {
+				return
+			}
+			klog.Infof("TLS Cert or Key updated - reloading")
+			if err := kpr.maybeReload(); err != nil {
+				klog.Errorf("Could not reload: %v", err)
+			} else {
+				klog.Infof("Reload complete")
+			}
+		}
 	}
-	err = result.fileWatcher.Add(keyPath)
+}
+
+// Loads the key pair from the source, making sure it meets the requirements.
+func (kpr *keypairReloader) load() (*tls.Certificate, error) {
+	cert, err := kpr.source.Certificate()
 	if err != nil {
-		klog.Error(err)
 		return nil, err
 	}
 
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					klog.Infof("TLS Cert or Key updated - reloading")
-					if err := result.maybeReload(); err != nil {
-						klog.Errorf("Could not reload: %v", err)
-					} else {
-						klog.Infof("Reload complete")
-					}
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				klog.Error(err)
-			}
+	if len(cert.Certificate) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
 		}
-	}()
+	}
 
-	return result, nil
+	if err := kpr.requirements.validate(cert, time.Now()); err != nil {
+		return nil, fmt.Errorf("rejected certificate: %w", err)
+	}
+
+	return cert, nil
 }
 
 // Attempts to reload the certificates.
+// The current certificate is kept if the new one cannot be loaded or is rejected.
 func (kpr *keypairReloader) maybeReload() error {
-	newCert, err := tls.LoadX509KeyPair(kpr.certPath, kpr.keyPath)
+	newCert, err := kpr.load()
+
+	kpr.certMu.Lock()
+	if err == nil {
+		kpr.cert = newCert
+	}
+	metrics, serverName := kpr.metrics, kpr.serverName
+	kpr.certMu.Unlock()
+
+	if metrics != nil {
+		metrics.observeReload(serverName, err, kpr.certificateInfo())
+	}
+
 	if err != nil {
 		return err
 	}
-	kpr.certMu.Lock()
-	defer kpr.certMu.Unlock()
-	kpr.cert = &newCert
+
+	kpr.checkExpiry(time.Now())
 	return nil
 }
 
+// Reports the reloads and the expiry of the certificate to the metrics.
+// The server name is empty for the default certificate.
+func (kpr *keypairReloader) observe(metrics *webhookMetrics, serverName string) {
+	kpr.certMu.Lock()
+	kpr.metrics = metrics
+	kpr.serverName = serverName
+	kpr.certMu.Unlock()
+
+	metrics.observeCertificate(serverName, kpr.certificateInfo())
+}
+
+// Returns a description of the certificate being served.
+func (kpr *keypairReloader) certificateInfo() certificateInfo {
+	kpr.certMu.RLock()
+	defer kpr.certMu.RUnlock()
+
+	return certificateInfo{
+		NotAfter:     kpr.cert.Leaf.NotAfter,
+		SerialNumber: kpr.cert.Leaf.SerialNumber,
+		Subject:      kpr.cert.Leaf.Subject.String(),
+	}
+}
+
+// Checks the expiry of the certificate every expiryCheckPeriod.
+func (kpr *keypairReloader) monitorExpiry() {
+	ticker := time.NewTicker(expiryCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-kpr.stop:
+			return
+		case now := <-ticker.C:
+			kpr.checkExpiry(now)
+		}
+	}
+}
+
+// Logs a warning the first time the remaining validity of
+// the certificate drops below each of the expiryWarnings.
+// Returns whether a warning was logged.
+func (kpr *keypairReloader) checkExpiry(now time.Time) bool {
+	info := kpr.certificateInfo()
+	remaining := info.NotAfter.Sub(now)
+
+	kpr.expiryMu.Lock()
+	defer kpr.expiryMu.Unlock()
+
+	// Start over for a new certificate
+	if serial := info.SerialNumber.String(); serial != kpr.warnedSerial {
+		kpr.warnedSerial = serial
+		kpr.warned = map[time.Duration]bool{}
+	}
+
+	warn := false
+	for _, threshold := range kpr.expiryWarnings {
+		if remaining < threshold && !kpr.warned[threshold] {
+			kpr.warned[threshold] = true
+			warn = true
+		}
+	}
+
+	if warn {
+		if remaining <= 0 {
+			klog.Errorf("TLS Cert %q (serial %s) expired on %s", info.Subject, info.SerialNumber, info.NotAfter.Format(time.RFC3339))
+		} else {
+			klog.Warningf("TLS Cert %q (serial %s) expires in %s, on %s", info.Subject, info.SerialNumber, remaining.Round(time.Second), info.NotAfter.Format(time.RFC3339))
+		}
+	}
+
+	return warn
+}
+
+// Stops reloading and closes the source.
+func (kpr *keypairReloader) Close() error {
+	kpr.closeMu.Lock()
+	defer kpr.closeMu.Unlock()
+
+	if kpr.closed {
+		return nil
+	}
+
+	kpr.closed = true
+	close(kpr.stop)
+	return kpr.source.Close()
+}
+
 // Function which is used to replace
 // http.Server.TLSConfig.GetCertificate so that the certificates can be reloaded.
 func (kpr *keypairReloader) GetCertificateFunc() func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
@@ -0,0 +1,152 @@
+package mutatingwebhook
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns the common name of the certificate currently served.
+func servedCommonName(t *testing.T, kpr *keypairReloader) string {
+	cert, err := kpr.GetCertificateFunc()(nil)
+	assert.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err)
+
+	return leaf.Subject.CommonName
+}
+
+// Helper creating a keypairReloader for the tls.cert and tls.key files in certDir.
+func newFileKeypairReloader(certDir string, configs MutatingWebhookConfigs) (*keypairReloader, error) {
+	source, err := NewFileCertificateSource(filepath.Join(certDir, "tls.cert"), filepath.Join(certDir, "tls.key"), *configs.WatchCerts, *configs.CertPollPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKeypairReloader(source, configs)
+}
+
+// Mimics how Kubernetes updates a mounted Secret: the files are symlinks
+// into ..data, itself a symlink to a timestamped directory which is swapped atomically.
+func TestReloadOnSymlinkSwap(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_symlink_test_%d", time.Now().UnixNano()))
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	first := filepath.Join(certDir, "..2021_01_01_00_00_00.1")
+	assert.NoError(t, os.Mkdir(first, 0770))
+	assert.NoError(t, writeCerts(first, "webhook1"))
+	assert.NoError(t, os.Symlink(filepath.Base(first), filepath.Join(certDir, "..data")))
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "tls.cert"), filepath.Join(certDir, "tls.cert")))
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "tls.key"), filepath.Join(certDir, "tls.key")))
+
+	kpr, err := newFileKeypairReloader(certDir, setDefaults(MutatingWebhookConfigs{}))
+	assert.NoError(t, err)
+	defer kpr.Close()
+
+	assert.Equal(t, "webhook1", servedCommonName(t, kpr))
+
+	// Swap in the new certificates
+	second := filepath.Join(certDir, "..2021_01_02_00_00_00.2")
+	assert.NoError(t, os.Mkdir(second, 0770))
+	assert.NoError(t, writeCerts(second, "webhook2"))
+	assert.NoError(t, os.Symlink(filepath.Base(second), filepath.Join(certDir, "..data_tmp")))
+	assert.NoError(t, os.Rename(filepath.Join(certDir, "..data_tmp"), filepath.Join(certDir, "..data")))
+	assert.NoError(t, os.RemoveAll(first))
+
+	// Wait for reload
+	time.Sleep(4 * reloadDebounce)
+
+	assert.Equal(t, "webhook2", servedCommonName(t, kpr))
+}
+
+// A directory which is removed and recreated is watched again.
+func TestReloadAfterDirectoryRemoval(t *testing.T) {
+	parentDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_rewatch_test_%d", time.Now().UnixNano()))
+	certDir := filepath.Join(parentDir, "certs")
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(parentDir)
+
+	assert.NoError(t, writeCerts(certDir, "webhook1"))
+
+	kpr, err := newFileKeypairReloader(certDir, setDefaults(MutatingWebhookConfigs{}))
+	assert.NoError(t, err)
+	defer kpr.Close()
+
+	assert.NoError(t, os.RemoveAll(certDir))
+	time.Sleep(4 * reloadDebounce)
+
+	// The previous certificate keeps being served
+	assert.Equal(t, "webhook1", servedCommonName(t, kpr))
+
+	assert.NoError(t, os.MkdirAll(certDir, 0770))
+	assert.NoError(t, writeCerts(certDir, "webhook2"))
+
+	// Wait for the directory to be watched again
+	time.Sleep(rewatchInterval + 4*reloadDebounce)
+
+	assert.Equal(t, "webhook2", servedCommonName(t, kpr))
+}
+
+// Without file system events the files are polled.
+func TestReloadByPolling(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_poll_test_%d", time.Now().UnixNano()))
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	assert.NoError(t, writeCerts(certDir, "webhook1"))
+
+	watch := false
+	period := 20 * time.Millisecond
+	kpr, err := newFileKeypairReloader(certDir, setDefaults(MutatingWebhookConfigs{
+		WatchCerts:     &watch,
+		CertPollPeriod: &period,
+	}))
+	assert.NoError(t, err)
+	defer kpr.Close()
+
+	assert.Nil(t, kpr.source.(*fileCertificateSource).fileWatcher)
+
+	assert.NoError(t, writeCerts(certDir, "webhook2"))
+
+	// Wait for the next poll and reload
+	time.Sleep(2*period + 4*reloadDebounce)
+
+	assert.Equal(t, "webhook2", servedCommonName(t, kpr))
+}
+
+func TestCheckExpiry(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_expiry_test_%d", time.Now().UnixNano()))
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	// The certificate is valid for a day
+	assert.NoError(t, writeCerts(certDir, "webhook1"))
+
+	kpr, err := newFileKeypairReloader(certDir, setDefaults(MutatingWebhookConfigs{
+		CertExpiryWarnings: []time.Duration{12 * time.Hour, time.Hour},
+	}))
+	assert.NoError(t, err)
+	defer kpr.Close()
+
+	info := kpr.certificateInfo()
+	assert.Contains(t, info.Subject, "CN=webhook1")
+	assert.Equal(t, int64(2019), info.SerialNumber.Int64())
+
+	// Each threshold is only reported once
+	assert.False(t, kpr.checkExpiry(time.Now()))
+	assert.True(t, kpr.checkExpiry(info.NotAfter.Add(-6*time.Hour)))
+	assert.False(t, kpr.checkExpiry(info.NotAfter.Add(-5*time.Hour)))
+	assert.True(t, kpr.checkExpiry(info.NotAfter.Add(-time.Minute)))
+	assert.False(t, kpr.checkExpiry(info.NotAfter.Add(time.Minute)))
+}
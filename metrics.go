@@ -0,0 +1,153 @@
+package mutatingwebhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	v1 "k8s.io/api/admission/v1"
+)
+
+const metricsNamespace = "mutating_webhook"
+
+// The phases of handling an AdmissionReview whose latency is measured.
+const (
+	phaseDecode = "decode"
+	phaseMutate = "mutate"
+	phaseEncode = "encode"
+)
+
+// The results by which admission requests are counted.
+const (
+	resultAllowed = "allowed"
+	resultPatched = "patched"
+	resultDenied  = "denied"
+	resultError   = "error"
+	resultInvalid = "invalid"
+)
+
+// The Prometheus metrics of a MutatingWebhook.
+// Each MutatingWebhook has its own registry, so that several can run in one process.
+type webhookMetrics struct {
+	registry *prometheus.Registry
+
+	requests       *prometheus.CounterVec
+	phaseDuration  *prometheus.HistogramVec
+	patchBytes     *prometheus.HistogramVec
+	patchOps       *prometheus.HistogramVec
+	certReloads    *prometheus.CounterVec
+	certExpiration *prometheus.GaugeVec
+}
+
+// Creates and registers the metrics.
+func newWebhookMetrics() *webhookMetrics {
+	metrics := &webhookMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "admission_requests_total",
+			Help:      "Number of admission requests handled, by webhook, operation, kind, namespace and result.",
+		}, []string{"webhook", "operation", "kind", "namespace", "result"}),
+		phaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "admission_phase_duration_seconds",
+			Help:      "Time spent decoding, mutating and encoding admission reviews.",
+			Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5, 10},
+		}, []string{"webhook", "phase"}),
+		patchBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "admission_patch_size_bytes",
+			Help:      "Size of the JSON patches returned.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+		}, []string{"webhook"}),
+		patchOps: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "admission_patch_operations",
+			Help:      "Number of operations in the JSON patches returned.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 8),
+		}, []string{"webhook"}),
+		certReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "certificate_reloads_total",
+			Help:      "Number of certificate reloads, by server name and result.",
+		}, []string{"server_name", "result"}),
+		certExpiration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "certificate_expiration_timestamp_seconds",
+			Help:      "Time at which the served certificate expires, by server name.",
+		}, []string{"server_name"}),
+	}
+
+	metrics.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		metrics.requests,
+		metrics.phaseDuration,
+		metrics.patchBytes,
+		metrics.patchOps,
+		metrics.certReloads,
+		metrics.certExpiration,
+	)
+
+	return metrics
+}
+
+// Serves the metrics in the Prometheus format.
+func (metrics *webhookMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{})
+}
+
+// Counts an admission request. The request is nil when the review could not be decoded.
+func (metrics *webhookMetrics) observeRequest(webhook string, request *v1.AdmissionRequest, result string) {
+	var operation, kind, namespace string
+	if request != nil {
+		operation, kind, namespace = string(request.Operation), request.Kind.Kind, request.Namespace
+	}
+
+	metrics.requests.WithLabelValues(webhook, operation, kind, namespace, result).Inc()
+}
+
+func (metrics *webhookMetrics) observePhase(webhook, phase string, seconds float64) {
+	metrics.phaseDuration.WithLabelValues(webhook, phase).Observe(seconds)
+}
+
+func (metrics *webhookMetrics) observePatch(webhook string, bytes, operations int) {
+	metrics.patchBytes.WithLabelValues(webhook).Observe(float64(bytes))
+	metrics.patchOps.WithLabelValues(webhook).Observe(float64(operations))
+}
+
+// Counts a certificate reload and records the expiry of the served certificate.
+func (metrics *webhookMetrics) observeReload(serverName string, err error, info certificateInfo) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.certReloads.WithLabelValues(serverName, result).Inc()
+	metrics.observeCertificate(serverName, info)
+}
+
+func (metrics *webhookMetrics) observeCertificate(serverName string, info certificateInfo) {
+	metrics.certExpiration.WithLabelValues(serverName).Set(float64(info.NotAfter.Unix()))
+}
+
+// The result by which a response is counted.
+func responseResult(response v1.AdmissionResponse) string {
+	switch {
+	case !response.Allowed:
+		return resultDenied
+	case len(response.Patch) > 0:
+		return resultPatched
+	default:
+		return resultAllowed
+	}
+}
+
+// The number of operations in a JSON patch, or 0 if it is not one.
+func countPatchOperations(patch []byte) int {
+	var operations []json.RawMessage
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return 0
+	}
+	return len(operations)
+}
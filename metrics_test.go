@@ -0,0 +1,159 @@
+package mutatingwebhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountPatchOperations(t *testing.T) {
+	assert.Equal(t, 2, countPatchOperations([]byte(`[{"op":"add","path":"/a","value":1},{"op":"remove","path":"/b"}]`)))
+	assert.Equal(t, 0, countPatchOperations([]byte(`It has been mutated!`)))
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_metrics_test_%d", time.Now().UnixNano()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	enable := true
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath:  &certFile,
+		KeyFilePath:   &keyFile,
+		EnableMetrics: &enable,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mw.AddMutator("deny", AdaptMutator(&denyMute{})))
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	admission := getAdmission()
+	admission.Request.Namespace = "default"
+	admission.Request.Object.Object = &payload
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	for _, path := range []string{"/mutate", "/mutate/deny"} {
+		resp, err := client.Post("https://localhost:8443"+path, "application/json", bytes.NewBuffer(requestBody))
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	resp, err := client.Post("https://localhost:8443/mutate", "application/json", bytes.NewBufferString("{}"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// Requests without JSON are counted too
+	for _, contentType := range []string{"", "text/plain"} {
+		resp, err := client.Post("https://localhost:8443/mutate/deny", contentType, bytes.NewBuffer(requestBody))
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	metrics := mw.(*mutatingWebhook).metrics
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("mutate", "CREATE", "MutatorTest", "default", resultPatched)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("mutate/deny", "CREATE", "MutatorTest", "default", resultDenied)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.requests.WithLabelValues("mutate", "", "", "", resultInvalid)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.requests.WithLabelValues("mutate/deny", "", "", "", resultInvalid)))
+
+	resp, err = client.Get("https://localhost:8443/metrics")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	for _, name := range []string{
+		"mutating_webhook_admission_requests_total",
+		`mutating_webhook_admission_phase_duration_seconds_count{phase="mutate",webhook="mutate"} 1`,
+		"mutating_webhook_admission_patch_size_bytes",
+		"mutating_webhook_certificate_expiration_timestamp_seconds",
+	} {
+		assert.Contains(t, string(body), name)
+	}
+}
+
+func TestMetricsSeparateListener(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_metrics_test_%d", time.Now().UnixNano()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	enable := true
+	metricsAddr := "localhost:8444"
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath:  &certFile,
+		KeyFilePath:   &keyFile,
+		EnableMetrics: &enable,
+		MetricsAddr:   &metricsAddr,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Get("http://localhost:8444/metrics")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Not served by the webhook's own server
+	resp2, err := getClient().Get("https://localhost:8443/metrics")
+	assert.NoError(t, err)
+	defer resp2.Body.Close()
+	body, err := ioutil.ReadAll(resp2.Body)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), "mutating_webhook_")
+}
+
+func TestReloadMetrics(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_metrics_test_%d", time.Now().UnixNano()))
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	assert.NoError(t, writeCerts(certDir, "webhook1"))
+
+	kpr, err := newFileKeypairReloader(certDir, setDefaults(MutatingWebhookConfigs{}))
+	assert.NoError(t, err)
+	defer kpr.Close()
+
+	metrics := newWebhookMetrics()
+	kpr.observe(metrics, "")
+
+	info := kpr.certificateInfo()
+	assert.Equal(t, float64(info.NotAfter.Unix()), testutil.ToFloat64(metrics.certExpiration.WithLabelValues("")))
+
+	assert.NoError(t, kpr.maybeReload())
+	assert.NoError(t, os.Remove(filepath.Join(certDir, "tls.key")))
+	assert.Error(t, kpr.maybeReload())
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.certReloads.WithLabelValues("", "success")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.certReloads.WithLabelValues("", "failure")))
+}
@@ -4,21 +4,36 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"mime"
 	"net"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/http2"
 	v1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/klog/v2"
 )
 
 const internalServerError = "an internal server error has occurred"
 
+// The TypeMeta of the AdmissionReview returned when the incoming one does not specify it.
+var admissionReviewTypeMeta = metav1.TypeMeta{
+	APIVersion: v1.SchemeGroupVersion.String(),
+	Kind:       "AdmissionReview",
+}
+
 // Based on:
 // https://medium.com/ovni/writing-a-very-basic-kubernetes-mutating-admission-webhook-398dbbcb63ec
 // https://github.com/alex-leonhardt/k8s-mutate-webhook
@@ -29,6 +44,29 @@ type Mutator interface {
 	Mutate(request v1.AdmissionRequest) (v1.AdmissionResponse, error)
 }
 
+// The ContextMutator interface is the context-aware variant of Mutator.
+// The context is derived from the incoming request and is cancelled when
+// the API server gives up on the request, when MutateTimeout elapses or
+// when the server is shut down.
+type ContextMutator interface {
+	Mutate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error)
+}
+
+// mutatorAdapter allows a Mutator to be used where a ContextMutator is expected.
+type mutatorAdapter struct {
+	mutator Mutator
+}
+
+func (ma *mutatorAdapter) Mutate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	return ma.mutator.Mutate(request)
+}
+
+// Wraps a Mutator so that it can be used as a ContextMutator.
+// The context is ignored.
+func AdaptMutator(mutator Mutator) ContextMutator {
+	return &mutatorAdapter{mutator: mutator}
+}
+
 // The basic functions that are needed from a Server.
 // Basic but opionated.
 type MutatingWebhook interface {
@@ -36,10 +74,50 @@ type MutatingWebhook interface {
 	Shutdown(ctx context.Context) error
 }
 
+// An AdmissionWebhook is a MutatingWebhook which can serve several
+// mutators, validators and converters. It is returned by the constructors,
+// and kept apart from MutatingWebhook so that its implementations still satisfy it.
+type AdmissionWebhook interface {
+	MutatingWebhook
+	// Serves an additional mutator on /mutate/<name>.
+	// The name must be a DNS-1123 label and unique to the server.
+	AddMutator(name string, mutator ContextMutator) error
+	// Serves a validator on /validate/<name>.
+	// The name must be a DNS-1123 label and unique to the server.
+	AddValidator(name string, validator Validator) error
+	// Serves a converter on /convert/<name>.
+	// The name must be a DNS-1123 label and unique to the server.
+	AddConverter(name string, converter Converter) error
+}
+
 // A function meant to handle the root of the server.
 // For simpler debugging.
 func (mw *mutatingWebhook) handleRoot(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Hello from mutating-webhook! Mutation available on: /mutate")
+	mw.routesMu.RLock()
+	defer mw.routesMu.RUnlock()
+
+	var mutation, validation, conversion []string
+	for _, route := range mw.routes {
+		switch {
+		case strings.HasPrefix(route, "/validate"):
+			validation = append(validation, route)
+		case strings.HasPrefix(route, "/convert"):
+			conversion = append(conversion, route)
+		default:
+			mutation = append(mutation, route)
+		}
+	}
+
+	fmt.Fprintf(w, "Hello from mutating-webhook!")
+	if len(mutation) > 0 {
+		fmt.Fprintf(w, " Mutation available on: %s", strings.Join(mutation, ", "))
+	}
+	if len(validation) > 0 {
+		fmt.Fprintf(w, " Validation available on: %s", strings.Join(validation, ", "))
+	}
+	if len(conversion) > 0 {
+		fmt.Fprintf(w, " Conversion available on: %s", strings.Join(conversion, ", "))
+	}
 }
 
 // A Health endpoint to simplify use within an orchestrated environment.
@@ -47,132 +125,447 @@ func (mw *mutatingWebhook) handleHealthz(w http.ResponseWriter, r *http.Request)
 	fmt.Fprintf(w, "ok")
 }
 
-// handleMutate is what wraps the Mutator and serves the logic. of the Mutator.
-func (mw *mutatingWebhook) handleMutate(w http.ResponseWriter, r *http.Request) {
+// A Readiness endpoint which fails once the certificate
+// has expired or is within CertExpiryCritical of expiring.
+func (mw *mutatingWebhook) handleReady(w http.ResponseWriter, r *http.Request) {
+	for _, kpr := range mw.keypairReloaders() {
+		info := kpr.certificateInfo()
+		if time.Until(info.NotAfter) <= *mw.configs.CertExpiryCritical {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "certificate %q expires on %s", info.Subject, info.NotAfter.Format(time.RFC3339))
+			return
+		}
+	}
 
-	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
-	if err != nil {
-		klog.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "%s", internalServerError)
-		return
+	fmt.Fprintf(w, "ok")
+}
+
+// handleMutate is what wraps the named Mutator and serves the logic. of the Mutator.
+func (mw *mutatingWebhook) handleMutate(name string, mutator ContextMutator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mw.serveMutate(name, mutator, w, r)
 	}
+}
 
-	// Make sure content type is correct
-	if contentType != "application/json" {
-		klog.Warningf("contentType was not application/json")
-		w.WriteHeader(http.StatusUnsupportedMediaType)
-		fmt.Fprintf(w, "JSON is expected")
+func (mw *mutatingWebhook) serveMutate(name string, mutator ContextMutator, w http.ResponseWriter, r *http.Request) {
+	r, span := mw.startAdmissionSpan(name, r)
+	defer span.End()
+
+	received := time.Now()
+	if err := checkContentType(name, w, r); err != nil {
+		mw.metrics.observeRequest(name, nil, resultInvalid)
+		mw.audit(name, nil, nil, err, received)
 		return
 	}
 
+	start := received
+	_, decodeSpan := mw.startPhaseSpan(r.Context(), "decode")
+
 	// Decode the request
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		klog.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "%s", internalServerError)
+		klog.Errorf("%s: %v", name, err)
+		recordSpanError(decodeSpan, err)
+		decodeSpan.End()
+		mw.metrics.observeRequest(name, nil, resultError)
+		mw.audit(name, nil, nil, err, received)
+		mw.writeResponse(w, admissionReviewTypeMeta, mw.errorResponse("", http.StatusInternalServerError, err))
 		return
 	}
 	defer r.Body.Close()
 
-	klog.V(5).Infof("request body:\n%s", body)
-
 	// Attempt to get the AdmissionReview the request
-	admissionReview := v1.AdmissionReview{}
-	if err := json.Unmarshal(body, &admissionReview); err != nil {
-		klog.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "%s", internalServerError)
+	admissionReview, err := decodeAdmissionReview(body)
+	if err != nil {
+		klog.Errorf("%s: %v", name, err)
+		recordSpanError(decodeSpan, err)
+		decodeSpan.End()
+		mw.metrics.observeRequest(name, nil, resultInvalid)
+		mw.audit(name, nil, nil, err, received)
+		mw.writeResponse(w, admissionReviewTypeMeta, mw.errorResponse("", http.StatusBadRequest, err))
+		return
+	}
+
+	// Make sure there is something to mutate
+	if err := validateAdmissionReview(admissionReview); err != nil {
+		klog.Warningf("%s: invalid AdmissionReview: %v", name, err)
+		recordSpanError(decodeSpan, err)
+		decodeSpan.End()
+		mw.metrics.observeRequest(name, admissionReview.Request, resultInvalid)
+		mw.audit(name, admissionReview.Request, nil, err, received)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "invalid AdmissionReview: %s", err)
 		return
 	}
 
+	decodeSpan.End()
+	mw.metrics.observePhase(name, phaseDecode, time.Since(start).Seconds())
+	span.SetAttributes(requestAttributes(admissionReview.Request)...)
+
+	// Respond with the same apiVersion as the caller
+	typeMeta := admissionReviewTypeMeta
+	if admissionReview.APIVersion != "" {
+		typeMeta.APIVersion = admissionReview.APIVersion
+	}
+
+	// Bound the mutation by the configured deadline.
+	ctx, cancel := mw.requestContext(r)
+	defer cancel()
+
 	// Evaluate/Mutate the AdmissionRequest.
-	response, err := mw.mutator.Mutate(*admissionReview.Request)
+	// The mutator's context carries the span, so that it can add its own.
+	start = time.Now()
+	ctx, mutateSpan := mw.startPhaseSpan(ctx, "mutate")
+	response, err := mw.mutate(ctx, name, mutator, *admissionReview.Request)
+	mw.metrics.observePhase(name, phaseMutate, time.Since(start).Seconds())
+
+	result := responseResult(response)
 	if err != nil {
-		klog.Error(err)
+		klog.Errorf("%s: %v", name, err)
+		recordSpanError(mutateSpan, err)
+		// Keep what the mutator reported before failing, such as the warnings of a Chain
+		failure := mw.errorResponse(admissionReview.Request.UID, http.StatusInternalServerError, err)
+		failure.Warnings, failure.AuditAnnotations = response.Warnings, response.AuditAnnotations
+		response = failure
+		result = resultError
+		var denial *DenyError
+		if errors.As(err, &denial) {
+			result = resultDenied
+		}
+	}
+	mutateSpan.SetAttributes(attributeAllowed.Bool(response.Allowed))
+	mutateSpan.End()
+	span.SetAttributes(attributeAllowed.Bool(response.Allowed))
+
+	// The API server rejects responses whose UID does not match the request
+	if response.UID != admissionReview.Request.UID {
+		if response.UID != "" {
+			klog.Warningf("%s: mutator returned UID %q for request %q, overriding", name, response.UID, admissionReview.Request.UID)
+		}
+		response.UID = admissionReview.Request.UID
+	}
+
+	mw.metrics.observeRequest(name, admissionReview.Request, result)
+	if len(response.Patch) > 0 {
+		mw.metrics.observePatch(name, len(response.Patch), countPatchOperations(response.Patch))
+	}
+
+	start = time.Now()
+	_, marshalSpan := mw.startPhaseSpan(r.Context(), "marshal")
+	mw.writeResponse(w, typeMeta, response)
+	marshalSpan.End()
+	mw.metrics.observePhase(name, phaseEncode, time.Since(start).Seconds())
+
+	mw.audit(name, admissionReview.Request, &response, err, received)
+}
+
+// Makes sure the request carries JSON, responding with an error otherwise.
+// The returned error is the reason the request was rejected.
+func checkContentType(name string, w http.ResponseWriter, r *http.Request) error {
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		klog.Errorf("%s: %v", name, err)
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "%s", internalServerError)
-		return
+		// The parse error only describes the caller's header, so it is returned as is
+		fmt.Fprintf(w, "%s", err)
+		return err
+	}
+
+	// Make sure content type is correct
+	if contentType != "application/json" {
+		klog.Warningf("%s: contentType was not application/json", name)
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		fmt.Fprintf(w, "JSON is expected")
+		return fmt.Errorf("unsupported content type %q", contentType)
 	}
 
-	reviewResponse := v1.AdmissionReview{
-		Response: &response,
+	return nil
+}
+
+// Derives the context passed to mutators from the request, bounded by MutateTimeout.
+func (mw *mutatingWebhook) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if *mw.configs.MutateTimeout > 0 {
+		return context.WithTimeout(r.Context(), *mw.configs.MutateTimeout)
 	}
+	return context.WithCancel(r.Context())
+}
 
-	if body, err = json.Marshal(reviewResponse); err != nil {
+// Calls the mutator, turning a panic into an error so that it is
+// reported to the API server like any other failure.
+func (mw *mutatingWebhook) mutate(ctx context.Context, name string, mutator ContextMutator, request v1.AdmissionRequest) (response v1.AdmissionResponse, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			klog.Errorf("%s: mutator panicked: %v\n%s", name, p, debug.Stack())
+			err = fmt.Errorf("mutator panicked: %v", p)
+		}
+	}()
+
+	return mutator.Mutate(ctx, request)
+}
+
+// Wraps the AdmissionResponse in an AdmissionReview of the version in typeMeta and writes it.
+func (mw *mutatingWebhook) writeResponse(w http.ResponseWriter, typeMeta metav1.TypeMeta, response v1.AdmissionResponse) {
+	body, err := encodeAdmissionReview(typeMeta, response)
+	if err != nil {
 		klog.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintf(w, "%s", internalServerError)
 		return
 	}
 
-	klog.V(5).Infof("response body:\n%s", string(body))
-
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(body)
 }
 
+// Wraps a handler so that a panic is logged with its stack and
+// answered with a failure Status instead of dropping the connection.
+func recoverHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// Let the server handle deliberate aborts
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			klog.Errorf("panic serving %s: %v\n%s", r.URL.Path, p, debug.Stack())
+
+			body, err := json.Marshal(metav1.Status{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+				Status:   metav1.StatusFailure,
+				Code:     http.StatusInternalServerError,
+				Reason:   metav1.StatusReasonInternalError,
+				Message:  internalServerError,
+			})
+			if err != nil {
+				klog.Error(err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(body)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 type mutatingWebhook struct {
-	mutator     Mutator
-	configs     MutatingWebhookConfigs
-	server      *http.Server
-	fileWatcher *fsnotify.Watcher
+	configs MutatingWebhookConfigs
+	mux     *http.ServeMux
+	server  *http.Server
+	kpr     *keypairReloader
+	// The certificates served by server name, along with the default kpr.
+	sniKprs map[string]*keypairReloader
+	metrics *webhookMetrics
+	// Creates the spans of admission requests, from the context propagated by the caller.
+	tracer         trace.Tracer
+	propagator     propagation.TextMapPropagator
+	shutdownTracer func(context.Context) error
+	// The plain HTTP server of the metrics, nil when they are served by server.
+	metricsServer *http.Server
+	// The CAs verifying client certificates, nil when they are not verified.
+	clientCAs *clientCAReloader
+	// Cancels the base context of every request once the server shuts down.
+	cancel context.CancelFunc
+	// The paths on which mutators are served.
+	routesMu sync.RWMutex
+	routes   []string
 }
 
 // Creates a MutatingWebhook server.
+// The mutator is served on /mutate. It may be nil if only
+// mutators added with AddMutator are to be served.
 func NewMutatingWebhook(
 	mutator Mutator,
 	configs MutatingWebhookConfigs,
-) (MutatingWebhook, error) {
+) (AdmissionWebhook, error) {
+	if mutator == nil {
+		return NewContextMutatingWebhook(nil, configs)
+	}
+	return NewContextMutatingWebhook(AdaptMutator(mutator), configs)
+}
 
+// Creates a MutatingWebhook server which passes a request-scoped context
+// to the ContextMutator. See NewMutatingWebhook.
+func NewContextMutatingWebhook(
+	mutator ContextMutator,
+	configs MutatingWebhookConfigs,
+) (AdmissionWebhook, error) {
+	mw, err := newMutatingWebhook(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	if mutator != nil {
+		if err := mw.handle("/mutate", "mutate", mutator); err != nil {
+			mw.Shutdown(context.Background())
+			return nil, err
+		}
+	}
+
+	return mw, nil
+}
+
+// Creates the server and its TLS and health machinery, without any routes for admission.
+func newMutatingWebhook(configs MutatingWebhookConfigs) (*mutatingWebhook, error) {
 	configs = setDefaults(configs)
+	if err := validateClientAuth(configs); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newTLSConfig(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	baseCtx, cancel := context.WithCancel(context.Background())
 	mux := http.NewServeMux()
 	server := http.Server{
 		Addr:           *configs.Addr,
-		Handler:        mux,
+		Handler:        recoverHandler(mux),
 		ReadTimeout:    *configs.ReadTimeout,
 		WriteTimeout:   *configs.WriteTimeout,
 		MaxHeaderBytes: *configs.MaxHeaderBytes,
+		TLSConfig:      tlsConfig,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
 	}
 
 	mw := &mutatingWebhook{
-		mutator: mutator,
 		configs: configs,
+		mux:     mux,
 		server:  &server,
+		cancel:  cancel,
+		metrics: newWebhookMetrics(),
 	}
 
-	kpr, err := newKeypairReloader(*mw.configs.CertFilePath, *mw.configs.KeyFilePath)
+	source := mw.configs.CertificateSource
+	if source == nil {
+		if source, err = newConfiguredCertificateSource(mw.configs); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	kpr, err := newKeypairReloader(source, mw.configs)
 	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	mw.kpr = kpr
+
+	if mw.sniKprs, err = newSNIKeypairReloaders(mw.configs); err != nil {
+		cancel()
+		kpr.Close()
 		return nil, err
 	}
 
-	mw.fileWatcher = kpr.fileWatcher
+	for serverName, kpr := range mw.sniKprs {
+		kpr.observe(mw.metrics, serverName)
+	}
+	kpr.observe(mw.metrics, "")
+
+	if verifiesClientCert(*mw.configs.ClientAuth) {
+		clientCAs, err := newClientCAReloader(*mw.configs.ClientCAFilePath, *mw.configs.WatchCerts, *mw.configs.CertPollPeriod)
+		if err != nil {
+			mw.Shutdown(context.Background())
+			return nil, err
+		}
+		mw.clientCAs = clientCAs
+	}
 
 	if err := http2.ConfigureServer(mw.server, nil); err != nil {
+		mw.Shutdown(context.Background())
 		return nil, err
 	}
 
-	server.TLSConfig.GetCertificate = kpr.GetCertificateFunc()
+	server.TLSConfig.GetCertificate = mw.getCertificateFunc()
+	mw.tracer, mw.propagator, mw.shutdownTracer = newTracer(configs)
+	if *mw.configs.ClientAuth != tls.NoClientCert {
+		// Client certificates are only checked on the routes for admission,
+		// so that the probes stay reachable without one
+		server.TLSConfig.ClientAuth = tls.RequestClientCert
+	}
 
 	mux.HandleFunc("/", mw.handleRoot)
 	mux.HandleFunc("/_healthz", mw.handleHealthz)
-	mux.HandleFunc("/_ready", mw.handleHealthz)
-	mux.HandleFunc("/mutate", mw.handleMutate)
+	mux.HandleFunc("/_ready", mw.handleReady)
+
+	if *mw.configs.EnableMetrics {
+		if *mw.configs.MetricsAddr == "" {
+			mux.Handle("/metrics", mw.metrics.handler())
+		} else {
+			metricsMux := http.NewServeMux()
+			metricsMux.Handle("/metrics", mw.metrics.handler())
+			mw.metricsServer = &http.Server{
+				Addr:    *mw.configs.MetricsAddr,
+				Handler: metricsMux,
+			}
+		}
+	}
 
 	return mw, nil
 }
 
+// Serves an additional mutator on /mutate/<name>.
+func (mw *mutatingWebhook) AddMutator(name string, mutator ContextMutator) error {
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return fmt.Errorf("invalid mutator name %q: %s", name, strings.Join(errs, ", "))
+	}
+
+	return mw.handle("/mutate/"+name, "mutate/"+name, mutator)
+}
+
+// Registers the mutator's handler on path.
+func (mw *mutatingWebhook) handle(path, name string, mutator ContextMutator) error {
+	return mw.route(path, mw.handleMutate(name, mutator))
+}
+
+// Registers the handler on path, which must not already be in use.
+func (mw *mutatingWebhook) route(path string, handler http.HandlerFunc) error {
+	mw.routesMu.Lock()
+	defer mw.routesMu.Unlock()
+
+	for _, route := range mw.routes {
+		if route == path {
+			return fmt.Errorf("%s is already registered", path)
+		}
+	}
+
+	mw.routes = append(mw.routes, path)
+	mw.mux.HandleFunc(path, mw.requireClient(handler))
+	return nil
+}
+
 // Starts the webserver and serves:
 // - a welcome message on /
 // - the passed Mutator on /mutate
+// - the mutators added with AddMutator on /mutate/<name>
+// - the validators on /validate and /validate/<name>
+// - the converters on /convert and /convert/<name>
 // - a health probe on /_healthz
 // - a readiness probe on /_ready
 func (mw *mutatingWebhook) ListenAndServe() error {
 
 	klog.Infof("Listening on %s\n", *mw.configs.Addr)
 
+	if mw.metricsServer != nil {
+		klog.Infof("Serving metrics on %s\n", mw.metricsServer.Addr)
+		go func() {
+			if err := mw.metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+				klog.Errorf("metrics server: %v", err)
+			}
+		}()
+	}
+
 	ln, err := net.Listen("tcp", *mw.configs.Addr)
 	if err != nil {
 		return err
@@ -183,15 +576,45 @@ func (mw *mutatingWebhook) ListenAndServe() error {
 }
 
 // Shuts down the server and any resources it's using.
+// Mutations still in flight once ctx is done have their context cancelled.
 func (mw *mutatingWebhook) Shutdown(ctx context.Context) error {
 	var errors *multierror.Error
 
 	if err := mw.server.Shutdown(ctx); err != nil {
 		errors = multierror.Append(errors, err)
 	}
+	mw.cancel()
 
-	if err := mw.fileWatcher.Close(); err != nil {
-		errors = multierror.Append(errors, err)
+	if mw.metricsServer != nil {
+		if err := mw.metricsServer.Shutdown(ctx); err != nil {
+			errors = multierror.Append(errors, err)
+		}
+	}
+
+	for _, kpr := range mw.keypairReloaders() {
+		if err := kpr.Close(); err != nil {
+			errors = multierror.Append(errors, err)
+		}
+	}
+
+	if mw.clientCAs != nil {
+		if err := mw.clientCAs.Close(); err != nil {
+			errors = multierror.Append(errors, err)
+		}
+	}
+
+	for _, sink := range mw.configs.AuditSinks {
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errors = multierror.Append(errors, err)
+			}
+		}
+	}
+
+	if mw.shutdownTracer != nil {
+		if err := mw.shutdownTracer(ctx); err != nil {
+			errors = multierror.Append(errors, err)
+		}
 	}
 
 	return errors.ErrorOrNil()
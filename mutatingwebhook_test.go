@@ -14,6 +14,7 @@ import (
 	"io/ioutil"
 	"math/big"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -66,6 +67,48 @@ func (m *mute) Mutate(request v1.AdmissionRequest) (v1.AdmissionResponse, error)
 	}, nil
 }
 
+type ctxMute struct{}
+
+func (m *ctxMute) Mutate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return v1.AdmissionResponse{}, fmt.Errorf("context has no deadline")
+	}
+
+	return v1.AdmissionResponse{
+		Allowed: true,
+		Patch:   []byte("It has been mutated with a deadline!"),
+	}, nil
+}
+
+// Blocks until its context is done, reporting when it started and why it stopped.
+type blockingMute struct {
+	started chan struct{}
+	stopped chan error
+}
+
+func newBlockingMute() *blockingMute {
+	return &blockingMute{started: make(chan struct{}, 1), stopped: make(chan error, 1)}
+}
+
+func (m *blockingMute) Mutate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	m.started <- struct{}{}
+	<-ctx.Done()
+	m.stopped <- ctx.Err()
+	return v1.AdmissionResponse{}, ctx.Err()
+}
+
+type denyMute struct{}
+
+func (m *denyMute) Mutate(request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	return v1.AdmissionResponse{}, Deny(http.StatusForbidden, "Pods are not welcome here")
+}
+
+type panicMute struct{}
+
+func (m *panicMute) Mutate(request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	panic("the mutator is broken")
+}
+
 func TestIsCanServeAndShutdown(t *testing.T) {
 
 	// Setup cert location for testing
@@ -180,6 +223,46 @@ func TestReadyEndpoint(t *testing.T) {
 	assert.Equal(t, "ok", bodyString)
 }
 
+func TestReadyEndpointCertificateExpiring(t *testing.T) {
+
+	// Setup cert location for testing
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_certreload_test_%d", time.Now().Unix()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	// The certificate expires in a day
+	critical := 48 * time.Hour
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath:       &certFile,
+		KeyFilePath:        &keyFile,
+		CertExpiryCritical: &critical,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	resp, err := client.Get("https://localhost:8443/_ready")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// Liveness is unaffected
+	resp2, err := client.Get("https://localhost:8443/_healthz")
+	assert.NoError(t, err)
+	defer resp2.Body.Close()
+	assert.Equal(t, http.StatusOK, resp2.StatusCode)
+}
+
 func writeCerts(certDir, name string) error {
 	certFile := filepath.Join(certDir, "tls.cert")
 	keyFile := filepath.Join(certDir, "tls.key")
@@ -333,6 +416,11 @@ func TestCanMutate(t *testing.T) {
 
 	patchValue := string(admisionReturned.Response.Patch)
 	assert.Equal(t, "It has been mutated!", patchValue)
+
+	// The server fills in what the mutator left out
+	assert.Equal(t, "admission.k8s.io/v1", admisionReturned.APIVersion)
+	assert.Equal(t, "AdmissionReview", admisionReturned.Kind)
+	assert.Equal(t, admission.Request.UID, admisionReturned.Response.UID)
 }
 
 func TestRejectNonJSON(t *testing.T) {
@@ -423,6 +511,404 @@ func TestNoMediaType(t *testing.T) {
 	assert.Equal(t, "mime: no media type", body)
 }
 
+func TestCanMutateWithContext(t *testing.T) {
+
+	// Setup cert location for testing
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_certreload_test_%d", time.Now().Unix()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	timeout := time.Second
+	mw, err := NewContextMutatingWebhook(&ctxMute{}, MutatingWebhookConfigs{
+		CertFilePath:  &certFile,
+		KeyFilePath:   &keyFile,
+		MutateTimeout: &timeout,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	admission := getAdmission()
+	admission.Request.Object.Object = &payload
+
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	// Post an AdmissionReview to the mutate endpoint
+	resp, err := client.Post("https://localhost:8443/mutate", "application/json", bytes.NewBuffer(requestBody))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	admisionReturned := v1.AdmissionReview{}
+	err = json.Unmarshal(bodyBytes, &admisionReturned)
+	assert.NoError(t, err)
+
+	patchValue := string(admisionReturned.Response.Patch)
+	assert.Equal(t, "It has been mutated with a deadline!", patchValue)
+}
+
+func TestMutateTimeout(t *testing.T) {
+
+	// Setup cert location for testing
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_certreload_test_%d", time.Now().Unix()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	mutator := newBlockingMute()
+	timeout := 200 * time.Millisecond
+	mw, err := NewContextMutatingWebhook(mutator, MutatingWebhookConfigs{
+		CertFilePath:  &certFile,
+		KeyFilePath:   &keyFile,
+		MutateTimeout: &timeout,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	admission := getAdmission()
+	admission.Request.Object.Object = &payload
+
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	// The mutator is cancelled once MutateTimeout elapses
+	start := time.Now()
+	resp, err := client.Post("https://localhost:8443/mutate", "application/json", bytes.NewBuffer(requestBody))
+	elapsed := time.Since(start)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.GreaterOrEqual(t, int64(elapsed), int64(timeout))
+	assert.Less(t, int64(elapsed), int64(2*time.Second))
+	assert.Equal(t, context.DeadlineExceeded, <-mutator.stopped)
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	admisionReturned := v1.AdmissionReview{}
+	err = json.Unmarshal(bodyBytes, &admisionReturned)
+	assert.NoError(t, err)
+
+	assert.False(t, admisionReturned.Response.Allowed)
+	assert.Equal(t, int32(http.StatusInternalServerError), admisionReturned.Response.Result.Code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), admisionReturned.Response.Result.Message)
+}
+
+func TestMutateCancelledOnShutdown(t *testing.T) {
+
+	// Setup cert location for testing
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_certreload_test_%d", time.Now().Unix()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	mutator := newBlockingMute()
+	timeout := time.Minute
+	mw, err := NewContextMutatingWebhook(mutator, MutatingWebhookConfigs{
+		CertFilePath:  &certFile,
+		KeyFilePath:   &keyFile,
+		MutateTimeout: &timeout,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	admission := getAdmission()
+	admission.Request.Object.Object = &payload
+
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := client.Post("https://localhost:8443/mutate", "application/json", bytes.NewBuffer(requestBody))
+		assert.NoError(t, err)
+		responses <- resp
+	}()
+
+	select {
+	case <-mutator.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the mutator was not called")
+	}
+
+	// The mutation still in flight once the grace period is over is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, mw.Shutdown(ctx))
+
+	select {
+	case err := <-mutator.stopped:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("the mutator was not cancelled")
+	}
+
+	select {
+	case resp := <-responses:
+		if resp != nil {
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no response once the mutator was cancelled")
+	}
+}
+
+func TestMutateDenied(t *testing.T) {
+
+	// Setup cert location for testing
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_certreload_test_%d", time.Now().Unix()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	mw, err := NewMutatingWebhook(&denyMute{}, MutatingWebhookConfigs{
+		CertFilePath: &certFile,
+		KeyFilePath:  &keyFile,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	admission := getAdmission()
+	admission.Request.Object.Object = &payload
+
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	// Post an AdmissionReview to the mutate endpoint
+	resp, err := client.Post("https://localhost:8443/mutate", "application/json", bytes.NewBuffer(requestBody))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	admisionReturned := v1.AdmissionReview{}
+	err = json.Unmarshal(bodyBytes, &admisionReturned)
+	assert.NoError(t, err)
+
+	assert.False(t, admisionReturned.Response.Allowed)
+	assert.Equal(t, admission.Request.UID, admisionReturned.Response.UID)
+	assert.Equal(t, int32(http.StatusForbidden), admisionReturned.Response.Result.Code)
+	assert.Equal(t, "Pods are not welcome here", admisionReturned.Response.Result.Message)
+}
+
+func TestRejectEmptyReview(t *testing.T) {
+
+	// Setup cert location for testing
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_certreload_test_%d", time.Now().Unix()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath: &certFile,
+		KeyFilePath:  &keyFile,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	// Post an empty AdmissionReview to the mutate endpoint
+	resp, err := client.Post("https://localhost:8443/mutate", "application/json", bytes.NewBufferString("{}"))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	body := string(bodyBytes)
+
+	assert.Equal(t, "invalid AdmissionReview: request is missing", body)
+}
+
+func TestMutatorPanic(t *testing.T) {
+
+	// Setup cert location for testing
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_certreload_test_%d", time.Now().Unix()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	mw, err := NewMutatingWebhook(&panicMute{}, MutatingWebhookConfigs{
+		CertFilePath: &certFile,
+		KeyFilePath:  &keyFile,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	admission := getAdmission()
+	admission.Request.Object.Object = &payload
+
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	// Post an AdmissionReview to the mutate endpoint
+	resp, err := client.Post("https://localhost:8443/mutate", "application/json", bytes.NewBuffer(requestBody))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	admisionReturned := v1.AdmissionReview{}
+	err = json.Unmarshal(bodyBytes, &admisionReturned)
+	assert.NoError(t, err)
+
+	assert.False(t, admisionReturned.Response.Allowed)
+	assert.Equal(t, admission.Request.UID, admisionReturned.Response.UID)
+	assert.Equal(t, int32(http.StatusInternalServerError), admisionReturned.Response.Result.Code)
+}
+
+func TestRecoverHandler(t *testing.T) {
+	handler := recoverHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("the handler is broken")
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+	status := metav1.Status{}
+	err := json.Unmarshal(recorder.Body.Bytes(), &status)
+	assert.NoError(t, err)
+	assert.Equal(t, metav1.StatusFailure, status.Status)
+	assert.Equal(t, int32(http.StatusInternalServerError), status.Code)
+}
+
+func TestMultipleMutators(t *testing.T) {
+
+	// Setup cert location for testing
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_certreload_test_%d", time.Now().Unix()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath: &certFile,
+		KeyFilePath:  &keyFile,
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, mw.AddMutator("with-deadline", &ctxMute{}))
+	assert.NoError(t, mw.AddMutator("denied", AdaptMutator(&denyMute{})))
+	assert.Error(t, mw.AddMutator("denied", AdaptMutator(&denyMute{})))
+	assert.Error(t, mw.AddMutator("Not/A/Label", AdaptMutator(&denyMute{})))
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	// The root lists the routes
+	resp, err := client.Get("https://localhost:8443/")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello from mutating-webhook! Mutation available on: /mutate, /mutate/with-deadline, /mutate/denied", string(bodyBytes))
+
+	admission := getAdmission()
+	admission.Request.Object.Object = &payload
+
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	// Each route is served by its own mutator
+	for path, patch := range map[string]string{
+		"/mutate":               "It has been mutated!",
+		"/mutate/with-deadline": "It has been mutated with a deadline!",
+		"/mutate/denied":        "",
+	} {
+		resp, err := client.Post("https://localhost:8443"+path, "application/json", bytes.NewBuffer(requestBody))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+
+		admisionReturned := v1.AdmissionReview{}
+		err = json.Unmarshal(bodyBytes, &admisionReturned)
+		assert.NoError(t, err)
+
+		assert.Equal(t, patch, string(admisionReturned.Response.Patch), path)
+		assert.Equal(t, patch != "", admisionReturned.Response.Allowed, path)
+	}
+}
+
 // Helper for getting a client that will accept self-signed certs.
 func getClient() *http.Client {
 	tlsConfig := &tls.Config{
@@ -435,7 +921,8 @@ func getClient() *http.Client {
 func getAdmission() v1.AdmissionReview {
 	admission := v1.AdmissionReview{
 		Request: &v1.AdmissionRequest{
-			UID: "This is unique!",
+			UID:       "This is unique!",
+			Operation: v1.Create,
 			Kind: metav1.GroupVersionKind{
 				Group:   "mutating.statcan.gc.ca",
 				Version: "UberAlpha",
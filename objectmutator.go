@@ -0,0 +1,113 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/statcan/mutating-webhook/jsonpatch"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// An ObjectMutateFunc mutates obj in place.
+// obj and oldObj are the decoded Object and OldObject of the request,
+// or nil when the request does not carry them (for example, oldObj on CREATE).
+type ObjectMutateFunc func(ctx context.Context, request v1.AdmissionRequest, obj, oldObj runtime.Object) error
+
+type objectMutator struct {
+	newObject func() runtime.Object
+	mutate    ObjectMutateFunc
+}
+
+// Creates a ContextMutator which decodes the request's objects using newObject,
+// calls mutate and responds with the JSON patch between the object before and
+// after mutate was called. The request is allowed unless mutate returns an error.
+func NewObjectMutator(newObject func() runtime.Object, mutate ObjectMutateFunc) ContextMutator {
+	return &objectMutator{
+		newObject: newObject,
+		mutate:    mutate,
+	}
+}
+
+// Creates a ContextMutator for Pods. See NewObjectMutator.
+func NewPodMutator(mutate func(ctx context.Context, pod *corev1.Pod) error) ContextMutator {
+	return NewObjectMutator(
+		func() runtime.Object { return &corev1.Pod{} },
+		func(ctx context.Context, request v1.AdmissionRequest, obj, oldObj runtime.Object) error {
+			pod, ok := obj.(*corev1.Pod)
+			if !ok {
+				return nil
+			}
+			return mutate(ctx, pod)
+		},
+	)
+}
+
+func (om *objectMutator) Mutate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	response := v1.AdmissionResponse{
+		UID:     request.UID,
+		Allowed: true,
+	}
+
+	obj, err := om.decode(request.Object)
+	if err != nil {
+		return response, fmt.Errorf("unable to decode object: %w", err)
+	}
+
+	oldObj, err := om.decode(request.OldObject)
+	if err != nil {
+		return response, fmt.Errorf("unable to decode old object: %w", err)
+	}
+
+	// Nothing can be patched without an object
+	if obj == nil {
+		return response, om.mutate(ctx, request, obj, oldObj)
+	}
+
+	// Diff against the decoded object rather than the raw one so that
+	// fields unknown to the type are not removed by the patch.
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return response, err
+	}
+
+	if err := om.mutate(ctx, request, obj, oldObj); err != nil {
+		return response, err
+	}
+
+	modified, err := json.Marshal(obj)
+	if err != nil {
+		return response, err
+	}
+
+	patch, err := jsonpatch.CreatePatch(original, modified)
+	if err != nil {
+		return response, err
+	}
+
+	if len(patch) > 0 {
+		if response.Patch, err = json.Marshal(patch); err != nil {
+			return response, err
+		}
+		patchType := v1.PatchTypeJSONPatch
+		response.PatchType = &patchType
+	}
+
+	return response, nil
+}
+
+// Decodes the raw object into a new object, returning nil if there is none.
+func (om *objectMutator) decode(raw runtime.RawExtension) (runtime.Object, error) {
+	if len(raw.Raw) == 0 {
+		return nil, nil
+	}
+
+	obj := om.newObject()
+	if err := json.Unmarshal(raw.Raw, obj); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
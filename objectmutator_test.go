@@ -0,0 +1,88 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/statcan/mutating-webhook/jsonpatch"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPodMutator(t *testing.T) {
+	mutator := NewPodMutator(func(ctx context.Context, pod *corev1.Pod) error {
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels["app.kubernetes.io/managed-by"] = "mutating-webhook"
+		pod.Spec.Containers[0].Image = "otherimage"
+		return nil
+	})
+
+	raw, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	admission := getAdmission()
+	admission.Request.Object.Raw = raw
+
+	response, err := mutator.Mutate(context.TODO(), *admission.Request)
+	assert.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Equal(t, admission.Request.UID, response.UID)
+	assert.Equal(t, v1.PatchTypeJSONPatch, *response.PatchType)
+
+	// The patch applies to the original object
+	patch := jsonpatch.JSONPatch{}
+	err = json.Unmarshal(response.Patch, &patch)
+	assert.NoError(t, err)
+
+	patched, err := patch.Apply(raw)
+	assert.NoError(t, err)
+
+	pod := corev1.Pod{}
+	err = json.Unmarshal(patched, &pod)
+	assert.NoError(t, err)
+	assert.Equal(t, "mutating-webhook", pod.Labels["app.kubernetes.io/managed-by"])
+	assert.Equal(t, "otherimage", pod.Spec.Containers[0].Image)
+}
+
+func TestObjectMutatorNoChanges(t *testing.T) {
+	var old runtime.Object
+	mutator := NewObjectMutator(
+		func() runtime.Object { return &corev1.Pod{} },
+		func(ctx context.Context, request v1.AdmissionRequest, obj, oldObj runtime.Object) error {
+			old = oldObj
+			return nil
+		},
+	)
+
+	raw, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	admission := getAdmission()
+	admission.Request.Operation = v1.Update
+	admission.Request.Object.Raw = raw
+	admission.Request.OldObject.Raw = raw
+
+	response, err := mutator.Mutate(context.TODO(), *admission.Request)
+	assert.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Nil(t, response.Patch)
+	assert.Nil(t, response.PatchType)
+	assert.Equal(t, payload.Spec, old.(*corev1.Pod).Spec)
+}
+
+func TestObjectMutatorDecodeError(t *testing.T) {
+	mutator := NewPodMutator(func(ctx context.Context, pod *corev1.Pod) error {
+		return nil
+	})
+
+	admission := getAdmission()
+	admission.Request.Object.Raw = []byte(`{"spec": "not a spec"}`)
+
+	_, err := mutator.Mutate(context.TODO(), *admission.Request)
+	assert.Error(t, err)
+}
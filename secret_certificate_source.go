@@ -0,0 +1,108 @@
+package mutatingwebhook
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// How long to wait for the Secret to be listed before giving up.
+const secretSyncTimeout = 30 * time.Second
+
+type secretCertificateSource struct {
+	namespace string
+	name      string
+	lister    listersv1.SecretNamespaceLister
+	changed   chan struct{}
+	// Closed to stop watching the Secret.
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// Creates a CertificateSource which reads the certificate and key from the
+// tls.crt and tls.key of a Secret, such as one managed by cert-manager.
+// The Secret is watched using the client, so that changes are picked up.
+func NewSecretCertificateSource(client kubernetes.Interface, namespace, name string) (CertificateSource, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+	secrets := factory.Core().V1().Secrets()
+
+	result := &secretCertificateSource{
+		namespace: namespace,
+		name:      name,
+		lister:    secrets.Lister().Secrets(namespace),
+		changed:   make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+
+	informer := secrets.Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { notifyChanged(result.changed) },
+		UpdateFunc: func(interface{}, interface{}) { notifyChanged(result.changed) },
+		DeleteFunc: func(interface{}) { notifyChanged(result.changed) },
+	})
+
+	factory.Start(result.stop)
+
+	synced := make(chan struct{})
+	timer := time.AfterFunc(secretSyncTimeout, func() { close(synced) })
+	defer timer.Stop()
+
+	if !cache.WaitForCacheSync(synced, informer.HasSynced) {
+		result.Close()
+		return nil, fmt.Errorf("timed out listing Secret %s/%s", namespace, name)
+	}
+
+	// The initial listing is not a change
+	select {
+	case <-result.changed:
+	default:
+	}
+
+	return result, nil
+}
+
+func (source *secretCertificateSource) Certificate() (*tls.Certificate, error) {
+	secret, err := source.lister.Get(source.name)
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM, ok := secret.Data[corev1.TLSCertKey]
+	if !ok {
+		return nil, fmt.Errorf("Secret %s/%s has no %s", source.namespace, source.name, corev1.TLSCertKey)
+	}
+	keyPEM, ok := secret.Data[corev1.TLSPrivateKeyKey]
+	if !ok {
+		return nil, fmt.Errorf("Secret %s/%s has no %s", source.namespace, source.name, corev1.TLSPrivateKeyKey)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func (source *secretCertificateSource) Changed() <-chan struct{} {
+	return source.changed
+}
+
+// Stops watching the Secret.
+func (source *secretCertificateSource) Close() error {
+	source.closeOnce.Do(func() { close(source.stop) })
+	return nil
+}
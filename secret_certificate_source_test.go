@@ -0,0 +1,68 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func tlsSecret(t *testing.T, name string) *corev1.Secret {
+	certPEM, keyPEM := pemKeypair(t, name)
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "webhook-tls"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}
+}
+
+func TestSecretCertificateSource(t *testing.T) {
+	client := fake.NewSimpleClientset(tlsSecret(t, "webhook1"))
+
+	source, err := NewSecretCertificateSource(client, "default", "webhook-tls")
+	assert.NoError(t, err)
+
+	kpr, err := newKeypairReloader(source, setDefaults(MutatingWebhookConfigs{}))
+	assert.NoError(t, err)
+	defer kpr.Close()
+
+	assert.Equal(t, "webhook1", servedCommonName(t, kpr))
+
+	_, err = client.CoreV1().Secrets("default").Update(context.TODO(), tlsSecret(t, "webhook2"), metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	// Wait for reload
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, "webhook2", servedCommonName(t, kpr))
+
+	// A Secret without a key is rejected and the previous certificate kept
+	invalid := tlsSecret(t, "webhook3")
+	delete(invalid.Data, corev1.TLSPrivateKeyKey)
+	_, err = client.CoreV1().Secrets("default").Update(context.TODO(), invalid, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Error(t, kpr.maybeReload())
+	assert.Equal(t, "webhook2", servedCommonName(t, kpr))
+}
+
+func TestSecretCertificateSourceMissing(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	source, err := NewSecretCertificateSource(client, "default", "webhook-tls")
+	assert.NoError(t, err)
+	defer source.Close()
+
+	_, err = source.Certificate()
+	assert.Error(t, err)
+}
@@ -0,0 +1,187 @@
+package mutatingwebhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// A certificate and key generated along with the CA which signed them, PEM encoded.
+type selfSignedKeypair struct {
+	CA   []byte
+	Cert []byte
+	Key  []byte
+}
+
+// Generates a CA and a serving certificate signed by it for the DNS names.
+// Both are valid from now for the given validity.
+func generateSelfSigned(dnsNames []string, validity time.Duration, now time.Time) (*selfSignedKeypair, error) {
+	if len(dnsNames) == 0 {
+		return nil, fmt.Errorf("no DNS names to generate a certificate for")
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	caSerial, err := randomSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber: caSerial,
+		Subject: pkix.Name{
+			CommonName: "mutating-webhook-ca",
+		},
+		// Allow for clock skew between the webhook and the API server
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := randomSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: dnsNames[0],
+		},
+		DNSNames:    dnsNames,
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(validity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &selfSignedKeypair{
+		CA:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+func randomSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// Writes the certificate, key and CA to the given file paths,
+// creating their directories if needed.
+func (ssk *selfSignedKeypair) write(certPath, keyPath, caPath string) error {
+	files := []struct {
+		path     string
+		contents []byte
+		mode     os.FileMode
+	}{
+		{caPath, ssk.CA, 0644},
+		{keyPath, ssk.Key, 0600},
+		// The certificate is written last, so that a reload sees the new key
+		{certPath, ssk.Cert, 0644},
+	}
+
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(file.path, file.contents, file.mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Whether the file does not exist.
+func isMissing(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	return false, err
+}
+
+// Creates the CertificateSource for the file configs. When SelfSignedDNSNames is set
+// and the cert or key file is absent, a self-signed certificate is generated
+// and served from memory, or written to the files and read from there.
+func newConfiguredCertificateSource(configs MutatingWebhookConfigs) (CertificateSource, error) {
+	certPath, keyPath := *configs.CertFilePath, *configs.KeyFilePath
+	fileSource := func() (CertificateSource, error) {
+		return NewFileCertificateSource(certPath, keyPath, *configs.WatchCerts, *configs.CertPollPeriod)
+	}
+
+	if len(configs.SelfSignedDNSNames) == 0 {
+		return fileSource()
+	}
+
+	certMissing, err := isMissing(certPath)
+	if err != nil {
+		return nil, err
+	}
+	keyMissing, err := isMissing(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !certMissing && !keyMissing {
+		return fileSource()
+	}
+
+	keypair, err := generateSelfSigned(configs.SelfSignedDNSNames, *configs.SelfSignedValidity, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("could not generate a self-signed certificate: %w", err)
+	}
+
+	if *configs.WriteSelfSigned {
+		if err := keypair.write(certPath, keyPath, *configs.SelfSignedCAFilePath); err != nil {
+			return nil, fmt.Errorf("could not write the self-signed certificate: %w", err)
+		}
+		klog.Infof("Generated a self-signed certificate for %v, its CA was written to %s", configs.SelfSignedDNSNames, *configs.SelfSignedCAFilePath)
+		return fileSource()
+	}
+
+	klog.Infof("Generated a self-signed certificate for %v, its caBundle is %s", configs.SelfSignedDNSNames, base64.StdEncoding.EncodeToString(keypair.CA))
+	return NewPEMCertificateSource(keypair.Cert, keypair.Key)
+}
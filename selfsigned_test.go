@@ -0,0 +1,133 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSelfSigned(t *testing.T) {
+	names := []string{"mutating-webhook.default.svc", "mutating-webhook.default.svc.cluster.local"}
+	keypair, err := generateSelfSigned(names, time.Hour, time.Now())
+	assert.NoError(t, err)
+
+	cert, err := tls.X509KeyPair(keypair.Cert, keypair.Key)
+	assert.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err)
+	assert.Equal(t, names, leaf.DNSNames)
+
+	pool := x509.NewCertPool()
+	assert.True(t, pool.AppendCertsFromPEM(keypair.CA))
+
+	for _, name := range names {
+		_, err := leaf.Verify(x509.VerifyOptions{
+			DNSName:   name,
+			Roots:     pool,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		assert.NoError(t, err, name)
+	}
+
+	_, err = generateSelfSigned(nil, time.Hour, time.Now())
+	assert.Error(t, err)
+}
+
+func TestSelfSignedInMemory(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_selfsigned_test_%d", time.Now().UnixNano()))
+	defer os.RemoveAll(certDir)
+
+	configs := setDefaults(MutatingWebhookConfigs{
+		CertFilePath:       stringPtr(filepath.Join(certDir, "tls.crt")),
+		KeyFilePath:        stringPtr(filepath.Join(certDir, "tls.key")),
+		SelfSignedDNSNames: []string{"mutating-webhook.default.svc"},
+	})
+
+	source, err := newConfiguredCertificateSource(configs)
+	assert.NoError(t, err)
+	kpr, err := newKeypairReloader(source, configs)
+	assert.NoError(t, err)
+	defer kpr.Close()
+
+	assert.Equal(t, "mutating-webhook.default.svc", servedCommonName(t, kpr))
+
+	// Nothing is written
+	_, err = os.Stat(certDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSelfSignedKeepsExistingFiles(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_selfsigned_test_%d", time.Now().UnixNano()))
+	assert.NoError(t, os.MkdirAll(certDir, 0770))
+	defer os.RemoveAll(certDir)
+
+	assert.NoError(t, writeCerts(certDir, "webhook1"))
+
+	configs := setDefaults(MutatingWebhookConfigs{
+		CertFilePath:       stringPtr(filepath.Join(certDir, "tls.cert")),
+		KeyFilePath:        stringPtr(filepath.Join(certDir, "tls.key")),
+		SelfSignedDNSNames: []string{"mutating-webhook.default.svc"},
+	})
+
+	source, err := newConfiguredCertificateSource(configs)
+	assert.NoError(t, err)
+	kpr, err := newKeypairReloader(source, configs)
+	assert.NoError(t, err)
+	defer kpr.Close()
+
+	assert.Equal(t, "webhook1", servedCommonName(t, kpr))
+}
+
+func TestServeSelfSignedWritten(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_selfsigned_test_%d", time.Now().UnixNano()))
+	defer os.RemoveAll(certDir)
+
+	caFile := filepath.Join(certDir, "ca.crt")
+	write := true
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath:         stringPtr(filepath.Join(certDir, "tls.crt")),
+		KeyFilePath:          stringPtr(filepath.Join(certDir, "tls.key")),
+		SelfSignedDNSNames:   []string{"localhost"},
+		WriteSelfSigned:      &write,
+		SelfSignedCAFilePath: &caFile,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	// The server is trusted by a client using the written CA
+	caPEM, err := ioutil.ReadFile(caFile)
+	assert.NoError(t, err)
+	pool := x509.NewCertPool()
+	assert.True(t, pool.AppendCertsFromPEM(caPEM))
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+
+	resp, err := client.Get("https://localhost:8443/_healthz")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	info, err := os.Stat(filepath.Join(certDir, "tls.key"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
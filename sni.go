@@ -0,0 +1,92 @@
+package mutatingwebhook
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Creates a keypairReloader for each of the SNICertificateSources,
+// which must be valid for the server name they are served for.
+// The sources are all closed if any of them cannot be used.
+func newSNIKeypairReloaders(configs MutatingWebhookConfigs) (map[string]*keypairReloader, error) {
+	names := make([]string, 0, len(configs.SNICertificateSources))
+	for name := range configs.SNICertificateSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := map[string]*keypairReloader{}
+	for i, name := range names {
+		source := configs.SNICertificateSources[name]
+		serverName := normalizeServerName(name)
+
+		var err error
+		if serverName == "" {
+			err = fmt.Errorf("invalid server name %q", name)
+			source.Close()
+		} else if _, ok := result[serverName]; ok {
+			err = fmt.Errorf("server name %q is configured more than once", name)
+			source.Close()
+		} else {
+			// Each certificate is validated for its own server name
+			sniConfigs := configs
+			sniConfigs.CertDNSNames = []string{serverName}
+			result[serverName], err = newKeypairReloader(source, sniConfigs)
+			if err != nil {
+				err = fmt.Errorf("certificate for %s: %w", serverName, err)
+			}
+		}
+
+		if err != nil {
+			for _, kpr := range result {
+				if kpr != nil {
+					kpr.Close()
+				}
+			}
+			for _, remaining := range names[i+1:] {
+				configs.SNICertificateSources[remaining].Close()
+			}
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// Server names are case insensitive, and may be fully qualified.
+func normalizeServerName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// Function which is used to replace http.Server.TLSConfig.GetCertificate.
+// The certificate configured for the server name requested by the client is
+// served, or the default certificate when there is none.
+func (mw *mutatingWebhook) getCertificateFunc() func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	fallback := mw.kpr.GetCertificateFunc()
+
+	return func(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if clientHello != nil {
+			if kpr, ok := mw.sniKprs[normalizeServerName(clientHello.ServerName)]; ok {
+				return kpr.GetCertificateFunc()(clientHello)
+			}
+		}
+		return fallback(clientHello)
+	}
+}
+
+// Returns the keypairReloaders of every certificate served, the default one first.
+func (mw *mutatingWebhook) keypairReloaders() []*keypairReloader {
+	names := make([]string, 0, len(mw.sniKprs))
+	for name := range mw.sniKprs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []*keypairReloader{mw.kpr}
+	for _, name := range names {
+		result = append(result, mw.sniKprs[name])
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper writing a certificate for serverName with the given common name to certDir.
+func writeServerCerts(t *testing.T, certDir, serverName, commonName string) {
+	template := serverTemplate(serverName)
+	template.Subject.CommonName = commonName
+	cert, _, key := newTestCertificate(t, template, nil, nil)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(certDir, "tls.key"), keyPEM, 0662))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(certDir, "tls.cert"), certPEM, 0662))
+}
+
+func pemSource(t *testing.T, name string) CertificateSource {
+	certPEM, keyPEM := pemKeypair(t, name)
+	source, err := NewPEMCertificateSource(certPEM, keyPEM)
+	assert.NoError(t, err)
+	return source
+}
+
+func TestSNICertificateSelection(t *testing.T) {
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertificateSource: pemSource(t, "webhook.default.svc"),
+		SNICertificateSources: map[string]CertificateSource{
+			"webhook.example.com": pemSource(t, "webhook.example.com"),
+		},
+	})
+	assert.NoError(t, err)
+	defer mw.Shutdown(context.TODO())
+
+	getCertificate := mw.(*mutatingWebhook).getCertificateFunc()
+	servedFor := func(serverName string) string {
+		cert, err := getCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		assert.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		assert.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+
+	assert.Equal(t, "webhook.example.com", servedFor("webhook.example.com"))
+	assert.Equal(t, "webhook.example.com", servedFor("Webhook.Example.com."))
+	assert.Equal(t, "webhook.default.svc", servedFor("webhook.default.svc"))
+	assert.Equal(t, "webhook.default.svc", servedFor(""))
+	assert.Equal(t, "webhook.default.svc", servedFor("other.example.com"))
+}
+
+// A certificate must be valid for the server name it is served for.
+func TestSNICertificateRejected(t *testing.T) {
+	_, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertificateSource: pemSource(t, "webhook.default.svc"),
+		SNICertificateSources: map[string]CertificateSource{
+			"webhook.example.com": pemSource(t, "other.example.com"),
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestSNICertificateReload(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_sni_test_%d", time.Now().UnixNano()))
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeServerCerts(t, certDir, "localhost", "external1")
+
+	external, err := NewFileCertificateSource(filepath.Join(certDir, "tls.cert"), filepath.Join(certDir, "tls.key"), true, 0)
+	assert.NoError(t, err)
+
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertificateSource: pemSource(t, "webhook.default.svc"),
+		SNICertificateSources: map[string]CertificateSource{
+			"localhost": external,
+		},
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	// A new client for each request, so that the TLS handshake is not reused
+	servedFor := func(serverName string) string {
+		client := getClient()
+		client.Transport.(*http.Transport).TLSClientConfig.ServerName = serverName
+		resp, err := client.Get("https://localhost:8443/_healthz")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	assert.Equal(t, "external1", servedFor("localhost"))
+	assert.Equal(t, "webhook.default.svc", servedFor("webhook.default.svc"))
+
+	writeServerCerts(t, certDir, "localhost", "external2")
+
+	// Wait for reload
+	time.Sleep(4 * reloadDebounce)
+
+	assert.Equal(t, "external2", servedFor("localhost"))
+	assert.Equal(t, "webhook.default.svc", servedFor("webhook.default.svc"))
+}
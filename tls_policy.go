@@ -0,0 +1,155 @@
+package mutatingwebhook
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// TLSProfile names a set of TLS versions, cipher suites and curves.
+type TLSProfile string
+
+const (
+	// Only TLS 1.3, for clients which all support it.
+	TLSProfileModern TLSProfile = "modern"
+	// TLS 1.2 and 1.3 with forward secret AEAD cipher suites.
+	TLSProfileIntermediate TLSProfile = "intermediate"
+	// Only TLS 1.2, restricted to the AES-GCM cipher suites and NIST curves approved by FIPS 140-2.
+	// TLS 1.3 is not accepted since crypto/tls does not allow restricting its cipher suites,
+	// which include TLS_CHACHA20_POLY1305_SHA256.
+	TLSProfileFIPS TLSProfile = "fips"
+)
+
+// The settings of a TLSProfile.
+type tlsPolicy struct {
+	minVersion uint16
+	// The highest version accepted, or 0 for the highest supported.
+	maxVersion   uint16
+	cipherSuites []uint16
+	curves       []tls.CurveID
+}
+
+// Based on https://wiki.mozilla.org/Security/Server_Side_TLS
+var tlsProfiles = map[TLSProfile]tlsPolicy{
+	TLSProfileModern: {
+		minVersion: tls.VersionTLS13,
+		curves:     []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+	TLSProfileIntermediate: {
+		minVersion: tls.VersionTLS12,
+		cipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+		curves: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+	TLSProfileFIPS: {
+		minVersion: tls.VersionTLS12,
+		maxVersion: tls.VersionTLS12,
+		cipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		},
+		curves: []tls.CurveID{tls.CurveP256, tls.CurveP384},
+	},
+}
+
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+var supportedCurves = map[tls.CurveID]bool{
+	tls.X25519:    true,
+	tls.CurveP256: true,
+	tls.CurveP384: true,
+	tls.CurveP521: true,
+}
+
+// Creates the TLS config for the profile and overrides of the configs,
+// making sure they are consistent.
+func newTLSConfig(configs MutatingWebhookConfigs) (*tls.Config, error) {
+	policy, ok := tlsProfiles[*configs.TLSProfile]
+	if !ok {
+		return nil, fmt.Errorf("unknown TLSProfile %q", *configs.TLSProfile)
+	}
+
+	if configs.TLSMinVersion != nil {
+		policy.minVersion = *configs.TLSMinVersion
+	}
+	if configs.TLSCipherSuites != nil {
+		policy.cipherSuites = configs.TLSCipherSuites
+	}
+	if configs.TLSCurves != nil {
+		policy.curves = configs.TLSCurves
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:               policy.minVersion,
+		MaxVersion:               policy.maxVersion,
+		CipherSuites:             policy.cipherSuites,
+		CurvePreferences:         policy.curves,
+		PreferServerCipherSuites: true,
+	}, nil
+}
+
+func (policy tlsPolicy) validate() error {
+	if _, ok := tlsVersionNames[policy.minVersion]; !ok {
+		return fmt.Errorf("unknown TLS version %#04x", policy.minVersion)
+	}
+
+	if policy.maxVersion != 0 && policy.minVersion > policy.maxVersion {
+		return fmt.Errorf("%s is above the highest version of the profile, %s", tlsVersionNames[policy.minVersion], tlsVersionNames[policy.maxVersion])
+	}
+
+	// The cipher suites of TLS 1.3 cannot be configured
+	if policy.minVersion == tls.VersionTLS13 && len(policy.cipherSuites) > 0 {
+		return fmt.Errorf("cipher suites cannot be configured for %s", tlsVersionNames[policy.minVersion])
+	}
+
+	// Only the TLS 1.2 suites can be configured, the TLS 1.3 ones would be ignored
+	secure := map[uint16]bool{}
+	for _, suite := range tls.CipherSuites() {
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				secure[suite.ID] = true
+			}
+		}
+	}
+
+	// HTTP/2 requires one of these cipher suites when TLS 1.2 is allowed
+	http2Compatible := policy.minVersion == tls.VersionTLS13 || policy.cipherSuites == nil
+	for _, suite := range policy.cipherSuites {
+		if !secure[suite] {
+			return fmt.Errorf("cipher suite %s is not a secure TLS 1.2 cipher suite", tls.CipherSuiteName(suite))
+		}
+		if suite == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || suite == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			http2Compatible = true
+		}
+	}
+	if !http2Compatible {
+		return fmt.Errorf("cipher suites must include TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 for HTTP/2")
+	}
+
+	if policy.curves != nil && len(policy.curves) == 0 {
+		return fmt.Errorf("no curves configured")
+	}
+	for _, curve := range policy.curves {
+		if !supportedCurves[curve] {
+			return fmt.Errorf("curve %d is not supported", curve)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	modern := TLSProfileModern
+	fips := TLSProfileFIPS
+	unknown := TLSProfile("paranoid")
+	tls10 := uint16(tls.VersionTLS10)
+	tls13 := uint16(tls.VersionTLS13)
+	invalidVersion := uint16(0x0305)
+
+	tests := []struct {
+		name    string
+		configs MutatingWebhookConfigs
+		isValid bool
+	}{
+		{"default", MutatingWebhookConfigs{}, true},
+		{"modern", MutatingWebhookConfigs{TLSProfile: &modern}, true},
+		{"fips", MutatingWebhookConfigs{TLSProfile: &fips}, true},
+		{"fips with TLS 1.3", MutatingWebhookConfigs{TLSProfile: &fips, TLSMinVersion: &tls13}, false},
+		{"unknown profile", MutatingWebhookConfigs{TLSProfile: &unknown}, false},
+		{"older version", MutatingWebhookConfigs{TLSMinVersion: &tls10}, true},
+		{"unknown version", MutatingWebhookConfigs{TLSMinVersion: &invalidVersion}, false},
+		{"cipher suites with TLS 1.3", MutatingWebhookConfigs{TLSMinVersion: &tls13}, false},
+		{"modern with cipher suites", MutatingWebhookConfigs{
+			TLSProfile:      &modern,
+			TLSCipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		}, false},
+		{"cipher suites", MutatingWebhookConfigs{
+			TLSCipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		}, true},
+		{"insecure cipher suite", MutatingWebhookConfigs{
+			TLSCipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_RC4_128_SHA},
+		}, false},
+		{"TLS 1.3 cipher suite", MutatingWebhookConfigs{
+			TLSCipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_AES_128_GCM_SHA256},
+		}, false},
+		{"missing HTTP/2 cipher suite", MutatingWebhookConfigs{
+			TLSCipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+		}, false},
+		{"curves", MutatingWebhookConfigs{TLSCurves: []tls.CurveID{tls.CurveP521}}, true},
+		{"no curves", MutatingWebhookConfigs{TLSCurves: []tls.CurveID{}}, false},
+		{"unknown curve", MutatingWebhookConfigs{TLSCurves: []tls.CurveID{42}}, false},
+	}
+
+	for _, test := range tests {
+		config, err := newTLSConfig(setDefaults(test.configs))
+		assert.Equal(t, test.isValid, err == nil, fmt.Sprintf("%s: %v", test.name, err))
+		if test.isValid {
+			assert.NotNil(t, config, test.name)
+		}
+	}
+
+	// The overrides replace the settings of the profile
+	config, err := newTLSConfig(setDefaults(MutatingWebhookConfigs{
+		TLSProfile: &fips,
+		TLSCurves:  []tls.CurveID{tls.CurveP384},
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MaxVersion)
+	assert.Equal(t, tlsProfiles[TLSProfileFIPS].cipherSuites, config.CipherSuites)
+	assert.Equal(t, []tls.CurveID{tls.CurveP384}, config.CurvePreferences)
+}
+
+func TestTLSMinVersionEnforced(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_tls_test_%d", time.Now().UnixNano()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	modern := TLSProfileModern
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath: &certFile,
+		KeyFilePath:  &keyFile,
+		TLSProfile:   &modern,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+	client.Transport.(*http.Transport).TLSClientConfig.MaxVersion = tls.VersionTLS12
+
+	_, err = client.Get("https://localhost:8443/_healthz")
+	assert.Error(t, err)
+
+	resp, err := getClient().Get("https://localhost:8443/_healthz")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+}
+
+func TestFIPSProfileOnlyTLS12(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_tls_test_%d", time.Now().UnixNano()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	fips := TLSProfileFIPS
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath: &certFile,
+		KeyFilePath:  &keyFile,
+		TLSProfile:   &fips,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	// A client preferring TLS 1.3 and ChaCha20 still gets TLS 1.2 with AES-GCM
+	client := getClient()
+	client.Transport.(*http.Transport).TLSClientConfig.CipherSuites = []uint16{
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	}
+
+	resp, err := client.Get("https://localhost:8443/_healthz")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, uint16(tls.VersionTLS12), resp.TLS.Version)
+	assert.Equal(t, uint16(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256), resp.TLS.CipherSuite)
+}
+
+func TestInvalidTLSPolicyRejected(t *testing.T) {
+	unknown := TLSProfile("paranoid")
+	_, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{TLSProfile: &unknown})
+	assert.Error(t, err)
+}
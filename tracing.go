@@ -0,0 +1,83 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/admission/v1"
+)
+
+// The name of the tracer creating the spans of the webhook.
+const tracerName = "github.com/statcan/mutating-webhook"
+
+// The attributes of the admission spans.
+const (
+	attributeUID       = attribute.Key("admission.uid")
+	attributeKind      = attribute.Key("admission.kind")
+	attributeNamespace = attribute.Key("admission.namespace")
+	attributeOperation = attribute.Key("admission.operation")
+	attributeAllowed   = attribute.Key("admission.allowed")
+	attributeWebhook   = attribute.Key("admission.webhook")
+)
+
+// Sets up tracing according to the configs. The TracerProvider is used if set,
+// otherwise one exporting to the TraceExporter is created, or spans are not recorded.
+// The returned function shuts down the TracerProvider created, if any.
+func newTracer(configs MutatingWebhookConfigs) (trace.Tracer, propagation.TextMapPropagator, func(context.Context) error) {
+	propagator := configs.TracePropagator
+	if propagator == nil {
+		propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+
+	shutdown := func(context.Context) error { return nil }
+
+	provider := configs.TracerProvider
+	if provider == nil {
+		if configs.TraceExporter != nil {
+			sdkProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(configs.TraceExporter))
+			provider, shutdown = sdkProvider, sdkProvider.Shutdown
+		} else {
+			provider = trace.NewNoopTracerProvider()
+		}
+	}
+
+	return provider.Tracer(tracerName), propagator, shutdown
+}
+
+// Starts the span of an admission request, continuing the trace of the caller if any.
+// The span is carried by the returned request's context.
+func (mw *mutatingWebhook) startAdmissionSpan(name string, r *http.Request) (*http.Request, trace.Span) {
+	ctx := mw.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := mw.tracer.Start(ctx, "admission "+name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attributeWebhook.String(name)),
+	)
+
+	return r.WithContext(ctx), span
+}
+
+// Starts the span of a phase of an admission request.
+func (mw *mutatingWebhook) startPhaseSpan(ctx context.Context, phase string) (context.Context, trace.Span) {
+	return mw.tracer.Start(ctx, phase)
+}
+
+// The attributes describing an admission request.
+func requestAttributes(request *v1.AdmissionRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attributeUID.String(string(request.UID)),
+		attributeKind.String(request.Kind.Kind),
+		attributeNamespace.String(request.Namespace),
+		attributeOperation.String(string(request.Operation)),
+	}
+}
+
+// Marks the span as failed.
+func recordSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,157 @@
+package mutatingwebhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/admission/v1"
+)
+
+// Records the span context passed to the mutator.
+type spanMute struct {
+	spanContext trace.SpanContext
+}
+
+func (sm *spanMute) Mutate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	sm.spanContext = trace.SpanContextFromContext(ctx)
+	return v1.AdmissionResponse{Allowed: true}, nil
+}
+
+// Keeps the exported spans on shutdown, unlike the InMemoryExporter.
+type keepingExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (keepingExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	result := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		result[kv.Key] = kv.Value
+	}
+	return result
+}
+
+func TestTracing(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_tracing_test_%d", time.Now().UnixNano()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	recorder := tracetest.NewSpanRecorder()
+	mutator := &spanMute{}
+	mw, err := NewContextMutatingWebhook(mutator, MutatingWebhookConfigs{
+		CertFilePath:   &certFile,
+		KeyFilePath:    &keyFile,
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	admission := getAdmission()
+	admission.Request.Namespace = "default"
+	admission.Request.Object.Object = &payload
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	// The API server's trace
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	request, err := http.NewRequest(http.MethodPost, "https://localhost:8443/mutate", bytes.NewBuffer(requestBody))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	resp, err := getClient().Do(request)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	root, ok := spans["admission mutate"]
+	assert.True(t, ok)
+	assert.Equal(t, traceID, root.SpanContext().TraceID().String())
+	assert.True(t, root.Parent().IsRemote())
+	assert.Equal(t, trace.SpanKindServer, root.SpanKind())
+
+	attributes := spanAttributes(root)
+	assert.Equal(t, string(admission.Request.UID), attributes[attributeUID].AsString())
+	assert.Equal(t, "MutatorTest", attributes[attributeKind].AsString())
+	assert.Equal(t, "default", attributes[attributeNamespace].AsString())
+	assert.Equal(t, "CREATE", attributes[attributeOperation].AsString())
+	assert.True(t, attributes[attributeAllowed].AsBool())
+
+	for _, phase := range []string{"decode", "mutate", "marshal"} {
+		span, ok := spans[phase]
+		assert.True(t, ok, phase)
+		if ok {
+			assert.Equal(t, root.SpanContext().SpanID(), span.Parent().SpanID(), phase)
+		}
+	}
+
+	// The mutator runs within the mutate span
+	assert.Equal(t, spans["mutate"].SpanContext().SpanID(), mutator.spanContext.SpanID())
+}
+
+func TestTraceExporter(t *testing.T) {
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_tracing_test_%d", time.Now().UnixNano()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	exporter := keepingExporter{tracetest.NewInMemoryExporter()}
+	mw, err := NewMutatingWebhook(&mute{}, MutatingWebhookConfigs{
+		CertFilePath:  &certFile,
+		KeyFilePath:   &keyFile,
+		TraceExporter: exporter,
+	})
+	assert.NoError(t, err)
+
+	go mw.ListenAndServe()
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := getClient().Post("https://localhost:8443/mutate", "application/json", bytes.NewBufferString("{}"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	// The spans are flushed on shutdown
+	mw.Shutdown(context.TODO())
+
+	spans := exporter.GetSpans()
+	names := []string{}
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	assert.Contains(t, names, "admission mutate")
+	assert.Contains(t, names, "decode")
+}
@@ -0,0 +1,76 @@
+package mutatingwebhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	v1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// The Validator interface is what is implemented to
+// pass the validation logic to the webserver.
+// A Validator only allows or denies requests; it must not return a patch.
+type Validator interface {
+	Validate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error)
+}
+
+// validatorAdapter serves a Validator through the mutation handler,
+// enforcing the semantics of a validating webhook.
+type validatorAdapter struct {
+	validator Validator
+}
+
+func (va *validatorAdapter) Mutate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	response, err := va.validator.Validate(ctx, request)
+	if err != nil {
+		return response, err
+	}
+
+	if len(response.Patch) > 0 || response.PatchType != nil {
+		return v1.AdmissionResponse{}, fmt.Errorf("validator returned a patch")
+	}
+
+	// Explain denials which do not carry a Result
+	if !response.Allowed && response.Result == nil {
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusForbidden,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: "denied by the validating webhook",
+		}
+	}
+
+	return response, nil
+}
+
+// Creates a MutatingWebhook server which serves the validator on /validate.
+// Mutators and other validators can be added to it like any other server.
+func NewValidatingWebhook(
+	validator Validator,
+	configs MutatingWebhookConfigs,
+) (AdmissionWebhook, error) {
+	mw, err := newMutatingWebhook(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mw.handle("/validate", "validate", &validatorAdapter{validator: validator}); err != nil {
+		mw.Shutdown(context.Background())
+		return nil, err
+	}
+
+	return mw, nil
+}
+
+// Serves a validator on /validate/<name>.
+func (mw *mutatingWebhook) AddValidator(name string, validator Validator) error {
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return fmt.Errorf("invalid validator name %q: %s", name, strings.Join(errs, ", "))
+	}
+
+	return mw.handle("/validate/"+name, "validate/"+name, &validatorAdapter{validator: validator})
+}
@@ -0,0 +1,134 @@
+package mutatingwebhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Only allows Pods with a container named after the payload's.
+type valid struct{}
+
+func (v *valid) Validate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	pod := corev1.Pod{}
+	if err := json.Unmarshal(request.Object.Raw, &pod); err != nil {
+		return v1.AdmissionResponse{}, err
+	}
+
+	return v1.AdmissionResponse{
+		Allowed: pod.Spec.Containers[0].Name == payload.Spec.Containers[0].Name,
+	}, nil
+}
+
+// A validator which wrongly tries to mutate.
+type patchingValid struct{}
+
+func (v *patchingValid) Validate(ctx context.Context, request v1.AdmissionRequest) (v1.AdmissionResponse, error) {
+	return v1.AdmissionResponse{
+		Allowed: true,
+		Patch:   []byte(`[]`),
+	}, nil
+}
+
+func TestValidatorAdapter(t *testing.T) {
+	raw, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	admission := getAdmission()
+	admission.Request.Object.Raw = raw
+
+	response, err := (&validatorAdapter{validator: &valid{}}).Mutate(context.TODO(), *admission.Request)
+	assert.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Nil(t, response.Result)
+
+	pod := payload.DeepCopy()
+	pod.Spec.Containers[0].Name = "someone-else"
+	admission.Request.Object.Raw, err = json.Marshal(pod)
+	assert.NoError(t, err)
+
+	response, err = (&validatorAdapter{validator: &valid{}}).Mutate(context.TODO(), *admission.Request)
+	assert.NoError(t, err)
+	assert.False(t, response.Allowed)
+	assert.Equal(t, metav1.StatusReasonForbidden, response.Result.Reason)
+	assert.Equal(t, int32(http.StatusForbidden), response.Result.Code)
+
+	_, err = (&validatorAdapter{validator: &patchingValid{}}).Mutate(context.TODO(), *admission.Request)
+	assert.EqualError(t, err, "validator returned a patch")
+}
+
+func TestCanValidate(t *testing.T) {
+
+	// Setup cert location for testing
+	certDir := filepath.Join(os.TempDir(), fmt.Sprintf("mutatingwebhook_certreload_test_%d", time.Now().Unix()))
+	certFile := filepath.Join(certDir, "tls.cert")
+	keyFile := filepath.Join(certDir, "tls.key")
+
+	err := os.MkdirAll(certDir, 0770)
+	assert.NoError(t, err)
+	defer os.RemoveAll(certDir)
+
+	writeCerts(certDir, "mutating-webhook")
+
+	mw, err := NewValidatingWebhook(&valid{}, MutatingWebhookConfigs{
+		CertFilePath: &certFile,
+		KeyFilePath:  &keyFile,
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, mw.AddValidator("patching", &patchingValid{}))
+	assert.NoError(t, mw.AddMutator("pods", AdaptMutator(&mute{})))
+
+	go mw.ListenAndServe()
+	defer mw.Shutdown(context.TODO())
+	time.Sleep(100 * time.Millisecond)
+
+	client := getClient()
+
+	// The root lists the routes
+	resp, err := client.Get("https://localhost:8443/")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello from mutating-webhook! Mutation available on: /mutate/pods Validation available on: /validate, /validate/patching", string(bodyBytes))
+
+	admission := getAdmission()
+	admission.Request.Object.Object = &payload
+
+	requestBody, err := json.Marshal(admission)
+	assert.NoError(t, err)
+
+	for path, allowed := range map[string]bool{
+		"/validate":          true,
+		"/validate/patching": false,
+	} {
+		resp, err := client.Post("https://localhost:8443"+path, "application/json", bytes.NewBuffer(requestBody))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+
+		admisionReturned := v1.AdmissionReview{}
+		err = json.Unmarshal(bodyBytes, &admisionReturned)
+		assert.NoError(t, err)
+
+		assert.Equal(t, allowed, admisionReturned.Response.Allowed, path)
+		assert.Empty(t, admisionReturned.Response.Patch, path)
+	}
+}